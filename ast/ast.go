@@ -16,14 +16,23 @@ type Token struct {
 type Error struct {
 	Pos scanner.Position
 	Err error
+
+	// Line is the BASIC line number of the statement that failed at
+	// run time, or 0 if the error was not raised by a statement.
+	Line int64
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("%v: %v", e.Pos, e.Err)
 }
 
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 type Stmt interface {
 	Line() int64
+	Pos() scanner.Position
 }
 
 type Expr interface{}
@@ -62,11 +71,56 @@ func (s *BaseStmt) Line() int64 {
 	return s.Label.Value
 }
 
+func (s *BaseStmt) Pos() scanner.Position {
+	return s.Label.Pos
+}
+
+// RemStmt is a numbered line with nothing but a comment on it. It does
+// nothing, but can be jumped to.
+type RemStmt struct {
+	BaseStmt
+}
+
 type EndStmt struct {
 	BaseStmt
 	End Token
 }
 
+// SubStmt starts the procedure Name, which extends to the next END SUB
+// and is run by CALL with its arguments bound to Params. Execution
+// reaching SUB itself skips over the procedure.
+type SubStmt struct {
+	BaseStmt
+	Sub    Token
+	Name   Variable
+	Params []Variable
+}
+
+// EndSubStmt ends a SUB, returning to the CALL that ran it.
+type EndSubStmt struct {
+	BaseStmt
+	End Token
+	Sub Token
+}
+
+// FunctionStmt starts a function, which is like a SUB but is called from
+// expressions and returns the value given to RETURN. It extends to the
+// next END FUNCTION.
+type FunctionStmt struct {
+	BaseStmt
+	Function Token
+	Name     Variable
+	Params   []Variable
+}
+
+// EndFunctionStmt ends a FUNCTION, returning zero or "" if no RETURN
+// gave a value.
+type EndFunctionStmt struct {
+	BaseStmt
+	End      Token
+	Function Token
+}
+
 type ForStmt struct {
 	BaseStmt
 	For   Token
@@ -76,25 +130,82 @@ type ForStmt struct {
 	End   Expr
 }
 
+// GotoStmt jumps to the line numbered Location, to the named label
+// Target if its name is set, or to the line numbered by the value of Expr
+// if it is not nil.
 type GotoStmt struct {
 	BaseStmt
 	Goto     Token
 	Location Number
+	Target   Variable
+	Expr     Expr
 }
 
+// GosubStmt calls the subroutine at Location, Target or Expr, as for
+// GotoStmt.
 type GosubStmt struct {
 	BaseStmt
 	Gosub    Token
 	Location Number
+	Target   Variable
+	Expr     Expr
+}
+
+// RunStmt clears all variables and restarts the program, at Location or
+// Target as for GotoStmt if either is set and at the first line otherwise.
+type RunStmt struct {
+	BaseStmt
+	Run      Token
+	Location *Number
+	Target   Variable
+}
+
+// OnErrorStmt makes runtime errors jump to Location or Target, as for
+// GotoStmt. ON ERROR GOTO 0 turns error handling off again.
+type OnErrorStmt struct {
+	BaseStmt
+	On       Token
+	Error    Token
+	Goto     Token
+	Location Number
+	Target   Variable
+}
+
+// ResumeStmt ends an error handler. It retries the statement that failed,
+// continues after it if Next is set, or continues at Location or Target
+// if either is set.
+type ResumeStmt struct {
+	BaseStmt
+	Resume   Token
+	Next     *Token
+	Location *Number
+	Target   Variable
+}
+
+// LabelStmt names its position in the program as a target for GOTO and
+// GOSUB. Unless Numbered is set it was written without a line number.
+type LabelStmt struct {
+	BaseStmt
+	Name     Variable
+	Numbered bool
 }
 
 type IfStmt struct {
 	BaseStmt
-	If   Token
-	Cond Expr
-	Then Token
-	Body Stmt
-	Else *ElseStmt
+	If     Token
+	Cond   Expr
+	Then   Token
+	Body   Stmt
+	ElseIf []*ElseIfStmt
+	Else   *ElseStmt
+}
+
+type ElseIfStmt struct {
+	BaseStmt
+	ElseIf Token
+	Cond   Expr
+	Then   Token
+	Body   Stmt
 }
 
 type ElseStmt struct {
@@ -103,19 +214,176 @@ type ElseStmt struct {
 	Body Stmt
 }
 
+// LetStmt assigns Value to Var, or to an element of it if Index is set.
 type LetStmt struct {
 	BaseStmt
 	Let   Token
 	Var   Variable
+	Index []Expr
+	Value Expr
+}
+
+// ConstStmt declares a constant. References to it are replaced by Value
+// when parsing, so it has no effect when executed.
+type ConstStmt struct {
+	BaseStmt
+	Const Token
+	Name  Variable
 	Value Expr
 }
 
+// DimStmt declares arrays, each written like a call whose arguments are
+// the largest index in each dimension.
+type DimStmt struct {
+	BaseStmt
+	Dim    Token
+	Arrays []*CallExpr
+}
+
+// PlotStmt plots the array X as a bar chart, or against the array Y as a
+// scatter plot if Y is set.
+type PlotStmt struct {
+	BaseStmt
+	Plot Token
+	X    Variable
+	Y    *Variable
+}
+
+// LocalStmt declares variables local to the enclosing GOSUB, SUB or
+// FUNCTION. Their previous values are restored when it returns.
+type LocalStmt struct {
+	BaseStmt
+	Local Token
+	Vars  []Variable
+}
+
+type GetStmt struct {
+	BaseStmt
+	Get Token
+	Var Variable
+}
+
+// CallStmt calls a procedure, discarding any value it returns. Without
+// an argument list X has no arguments and no parentheses.
+type CallStmt struct {
+	BaseStmt
+	Call Token
+	X    *CallExpr
+}
+
+// WaitStmt waits for asynchronous calls to Name to finish, or for all of
+// them if Name is empty, and stores the result of the last one in Var if
+// it is given.
+type WaitStmt struct {
+	BaseStmt
+	Wait Token
+	Name Variable
+	Var  *Variable
+}
+
+// OpenStmt opens the file Name on Channel. Mode is INPUT, OUTPUT or
+// APPEND.
+type OpenStmt struct {
+	BaseStmt
+	Open    Token
+	Name    Expr
+	For     Token
+	Mode    Token
+	As      Token
+	Channel Expr
+}
+
+// CloseStmt closes the files open on Channels, or all open files if there
+// are none.
+type CloseStmt struct {
+	BaseStmt
+	Close    Token
+	Channels []Expr
+}
+
+// PopStmt discards the return address of the innermost GOSUB.
+type PopStmt struct {
+	BaseStmt
+	Pop Token
+}
+
+// SysStmt calls the host routine at Addr with Args.
+type SysStmt struct {
+	BaseStmt
+	Sys  Token
+	Addr Expr
+	Args []Expr
+}
+
+// ClearStmt removes all variables and forgets open loops and GOSUBs.
+type ClearStmt struct {
+	BaseStmt
+	Clear Token
+}
+
+// ChainStmt replaces the program with the one in the file Name and runs
+// it from the start or from Location. Variables are kept if All is set.
+type ChainStmt struct {
+	BaseStmt
+	Chain    Token
+	Name     Expr
+	Location *Number
+	All      *Token
+}
+
+// IncludeStmt names a library or file whose lines are added to the
+// program when it is compiled.
+type IncludeStmt struct {
+	BaseStmt
+	Include Token
+	Path    String
+}
+
+// TraceStmt turns line tracing on for TRON and off for TROFF.
+type TraceStmt struct {
+	BaseStmt
+	Trace Token
+}
+
+type ClsStmt struct {
+	BaseStmt
+	Cls Token
+}
+
+type LocateStmt struct {
+	BaseStmt
+	Locate   Token
+	Row, Col Expr
+}
+
+// ColorStmt sets the foreground color and, if Bg is not nil, the
+// background color.
+type ColorStmt struct {
+	BaseStmt
+	Color Token
+	Fg    Expr
+	Bg    Expr
+}
+
+// NextStmt ends the FOR loop over Var, or the innermost loop if Var has
+// no name.
 type NextStmt struct {
 	BaseStmt
 	Next Token
 	Var  Variable
 }
 
+type WhileStmt struct {
+	BaseStmt
+	While Token
+	Cond  Expr
+}
+
+type WendStmt struct {
+	BaseStmt
+	Wend Token
+}
+
 type PeekStmt struct {
 	BaseStmt
 	Peek Token
@@ -123,22 +391,45 @@ type PeekStmt struct {
 	Var  Variable
 }
 
+// PokeStmt writes Value to Addr. Size is the width in bits written by
+// POKE8, POKE16 and POKE32, or 0 for POKE, which writes a whole value of
+// the machine.
 type PokeStmt struct {
 	BaseStmt
 	Poke  Token
+	Size  int
 	Addr  Expr
 	Value Expr
 }
 
+// PrintStmt writes Args to the screen, or to the file open on Channel if
+// it is not nil.
 type PrintStmt struct {
 	BaseStmt
-	Print Token
-	Args  []Expr
+	Print   Token
+	Channel Expr
+	Args    []Expr
 }
 
+// InputStmt reads a line of comma separated fields into Vars. If Prompt
+// is set it replaces the default prompt; Sep is the punctuation after it,
+// a semicolon keeping the default prompt after the given one. If Channel
+// is not nil the line is read from the file open on it instead.
+type InputStmt struct {
+	BaseStmt
+	Input   Token
+	Channel Expr
+	Prompt  *String
+	Sep     Punct
+	Vars    []Variable
+}
+
+// ReturnStmt returns from a GOSUB, SUB or FUNCTION, giving Value as the
+// result of a FUNCTION if it is not nil.
 type ReturnStmt struct {
 	BaseStmt
 	Return Token
+	Value  Expr
 }
 
 type BinaryExpr struct {
@@ -146,8 +437,41 @@ type BinaryExpr struct {
 	X, Y Expr
 }
 
+type UnaryExpr struct {
+	Op Token
+	X  Expr
+}
+
+type CallExpr struct {
+	Func   Variable
+	Lparen Token
+	Args   []Expr
+	Rparen Token
+}
+
 type ParenExpr struct {
 	Lparen Token
 	X      Expr
 	Rparen Token
 }
+
+// ExprPos returns the position where the expression e starts.
+func ExprPos(e Expr) scanner.Position {
+	switch e := e.(type) {
+	case Number:
+		return e.Pos
+	case String:
+		return e.Pos
+	case Variable:
+		return e.Pos
+	case *BinaryExpr:
+		return ExprPos(e.X)
+	case *UnaryExpr:
+		return e.Op.Pos
+	case *ParenExpr:
+		return e.Lparen.Pos
+	case *CallExpr:
+		return e.Func.Pos
+	}
+	return scanner.Position{}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// snapshot is what a program did when run one way: its output and the
+// error it stopped with, if any.
+type snapshot struct {
+	output string
+	err    string
+}
+
+// compare implements "ubasic diff", which runs every program in the given
+// directories two ways and reports those whose output or errors differ,
+// so the effect of a dialect or option change, or of upgrading to another
+// build of the interpreter, can be seen before committing to it.
+func compare(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	optsA := fs.String("a", "", "`options` for the first run, such as \"dialect=ubasic autonumber=true\"")
+	optsB := fs.String("b", "", "`options` for the second run")
+	bin := fs.String("bin", "", "run the second time with the ubasic `binary` at this path instead of this one")
+	parallel := fs.Int("parallel", 1, "number of programs to run at once")
+	maxSteps := fs.Int64("maxsteps", 1000000, "maximum statements executed per program (0 for no limit)")
+	timeout := fs.Duration("timeout", 10*time.Second, "maximum run time per program (0 for no limit)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: diff [options] dir ...")
+		fs.PrintDefaults()
+		os.Exit(exitUsage)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || *parallel < 1 || (*bin != "" && *optsB != "") {
+		fs.Usage()
+	}
+
+	confA := runConfig{maxSteps: *maxSteps, timeout: *timeout, options: *optsA}
+	confB := runConfig{maxSteps: *maxSteps, timeout: *timeout, options: *optsB}
+	names := programs(fs.Args())
+	snaps := make([][2]snapshot, len(names))
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer func() { <-sem; wg.Done() }()
+			snaps[i][0] = snapshotOf(name, confA)
+			if *bin != "" {
+				snaps[i][1] = snapshotExec(*bin, name, confA)
+			} else {
+				snaps[i][1] = snapshotOf(name, confB)
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PROGRAM\tRESULT\tLINE\tA\tB")
+	for i, name := range names {
+		a, b := snaps[i][0], snaps[i][1]
+		if a == b {
+			fmt.Fprintf(w, "%s\tsame\t\t\t\n", name)
+			continue
+		}
+		status = exitRuntime
+		if a.output == b.output {
+			fmt.Fprintf(w, "%s\terror\t\t%q\t%q\n", name, a.err, b.err)
+			continue
+		}
+		line, x, y := firstDiff(a.output, b.output)
+		fmt.Fprintf(w, "%s\toutput\t%d\t%q\t%q\n", name, line, x, y)
+	}
+	w.Flush()
+}
+
+// snapshotOf runs the program in this process.
+func snapshotOf(name string, conf runConfig) snapshot {
+	var out bytes.Buffer
+	conf.out = &out
+	r := runOne(name, conf)
+	s := snapshot{output: out.String()}
+	if r.err != nil {
+		s.err = r.err.Error()
+	}
+	return s
+}
+
+// snapshotExec runs the program with another build of the interpreter,
+// passing it the limits and options of conf. The build is killed if it
+// overruns its own time limit.
+func snapshotExec(bin, name string, conf runConfig) snapshot {
+	ctx := context.Background()
+	if conf.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 2*conf.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin,
+		"-maxsteps", strconv.FormatInt(conf.maxSteps, 10),
+		"-timeout", conf.timeout.String(),
+		"-options", conf.options,
+		name)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	s := snapshot{output: stdout.String()}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		s.err = strings.TrimPrefix(msg, "ubasic: ")
+	} else if err != nil {
+		s.err = err.Error()
+	}
+	return s
+}
+
+// firstDiff returns the first line, counting from 1, at which a and b
+// differ, and the text of that line in each.
+func firstDiff(a, b string) (line int, x, y string) {
+	la := strings.SplitAfter(a, "\n")
+	lb := strings.SplitAfter(b, "\n")
+	for i := 0; i < len(la) || i < len(lb); i++ {
+		x, y = "", ""
+		if i < len(la) {
+			x = la[i]
+		}
+		if i < len(lb) {
+			y = lb[i]
+		}
+		if x != y {
+			return i + 1, x, y
+		}
+	}
+	return 0, "", ""
+}
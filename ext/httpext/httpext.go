@@ -0,0 +1,78 @@
+// Package httpext provides builtins for fetching URLs, so that programs
+// can be used as glue in automation scripts. Importing the package
+// registers the builtins; they are gated by the "net" policy, which the
+// embedding program must allow on each interpreter that may use them.
+//
+//	import _ "github.com/qeedquan/go-ubasic/ext/httpext"
+//
+//	p.Allow = map[string]bool{httpext.Policy: true}
+package httpext
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+// Policy is the policy the builtins of this package require.
+const Policy = "net"
+
+// MaxBody is the most bytes of a response body returned to a program.
+const MaxBody = 1 << 20
+
+// Client is used to make all requests.
+var Client = &http.Client{Timeout: 30 * time.Second}
+
+type statusKey struct{}
+
+func init() {
+	interp.RegisterBuiltin(&interp.Builtin{
+		Name:   "HTTPGET$",
+		Syntax: "HTTPGET$(url$)",
+		Doc:    "the body of the response to a GET request for url$",
+		Params: []interp.Kind{interp.StringKind},
+		Result: interp.StringKind,
+		Policy: Policy,
+		Func:   get,
+	})
+	interp.RegisterBuiltin(&interp.Builtin{
+		Name:   "HTTPSTATUS",
+		Syntax: "HTTPSTATUS()",
+		Doc:    "the status code of the last HTTPGET$ response",
+		Result: interp.IntKind,
+		Policy: Policy,
+		Func: func(p *interp.Interpreter, args []interp.Value) (interp.Value, error) {
+			status, _ := p.ExtData(statusKey{}).(int)
+			return interp.Int(status), nil
+		},
+	})
+}
+
+func get(p *interp.Interpreter, args []interp.Value) (interp.Value, error) {
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	p.SetExtData(statusKey{}, 0)
+	req, err := http.NewRequest("GET", string(args[0].(interp.String)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p.SetExtData(statusKey{}, resp.StatusCode)
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBody))
+	if err != nil {
+		return nil, err
+	}
+	return interp.String(body), nil
+}
@@ -0,0 +1,124 @@
+// Package jsonext provides builtins for extracting fields from JSON
+// documents held in strings. Importing the package registers them.
+//
+// A path is a sequence of object keys and array indexes separated by
+// dots, as in "items.0.name"; indexes may also be written items[0].name.
+// The empty path refers to the whole document.
+package jsonext
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+func init() {
+	interp.RegisterBuiltin(&interp.Builtin{
+		Name:   "JSONGET$",
+		Syntax: "JSONGET$(doc$, path$)",
+		Doc:    "the string at path$ in doc$, other values as JSON, or \"\" if there is none",
+		Params: []interp.Kind{interp.StringKind, interp.StringKind},
+		Result: interp.StringKind,
+		Func: func(p *interp.Interpreter, args []interp.Value) (interp.Value, error) {
+			v, ok, err := lookup(args[0], args[1])
+			switch {
+			case err != nil:
+				return nil, err
+			case !ok || v == nil:
+				return interp.String(""), nil
+			}
+			if s, ok := v.(string); ok {
+				return interp.String(s), nil
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			return interp.String(b), nil
+		},
+	})
+	interp.RegisterBuiltin(&interp.Builtin{
+		Name:   "JSONNUM",
+		Syntax: "JSONNUM(doc$, path$)",
+		Doc:    "the number at path$ in doc$, 1 or 0 for booleans, or 0 if there is none",
+		Params: []interp.Kind{interp.StringKind, interp.StringKind},
+		Result: interp.FloatKind,
+		Func: func(p *interp.Interpreter, args []interp.Value) (interp.Value, error) {
+			v, _, err := lookup(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			switch v := v.(type) {
+			case json.Number:
+				if n, err := v.Int64(); err == nil {
+					return interp.Int(n), nil
+				}
+				f, err := v.Float64()
+				return interp.Float(f), err
+			case bool:
+				if v {
+					return interp.Int(1), nil
+				}
+				return interp.Int(0), nil
+			case nil:
+				return interp.Int(0), nil
+			}
+			return nil, fmt.Errorf("%s is not a number", args[1])
+		},
+	})
+}
+
+// lookup decodes doc and returns the value at path, reporting whether
+// there is one.
+func lookup(doc, path interp.Value) (interface{}, bool, error) {
+	dec := json.NewDecoder(strings.NewReader(string(doc.(interp.String))))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false, fmt.Errorf("invalid json: %v", err)
+	}
+
+	for _, elem := range split(string(path.(interp.String))) {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = x[elem]; !ok {
+				return nil, false, nil
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false, nil
+			}
+			v = x[i]
+		default:
+			return nil, false, nil
+		}
+	}
+	return v, true, nil
+}
+
+// split splits a path into its keys and indexes.
+func split(path string) []string {
+	var b bytes.Buffer
+	for _, r := range path {
+		switch r {
+		case '[':
+			b.WriteRune('.')
+		case ']':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	var elems []string
+	for _, elem := range strings.Split(b.String(), ".") {
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
@@ -0,0 +1,80 @@
+// Package regexpext provides regular expression builtins for text
+// processing scripts. Importing the package registers them; they are
+// gated by the "regexp" policy since patterns supplied by untrusted
+// programs can be expensive to compile.
+//
+// Patterns use the syntax of Go's regexp package.
+package regexpext
+
+import (
+	"regexp"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+// Policy is the policy the builtins of this package require.
+const Policy = "regexp"
+
+// maxCache is the number of compiled patterns kept per interpreter.
+const maxCache = 64
+
+type cacheKey struct{}
+
+func init() {
+	interp.RegisterBuiltin(&interp.Builtin{
+		Name:   "MATCH",
+		Syntax: "MATCH(a$, pattern$)",
+		Doc:    "the position of the first match of pattern$ in a$, counting from 1, or 0 if there is none",
+		Params: []interp.Kind{interp.StringKind, interp.StringKind},
+		Result: interp.IntKind,
+		Policy: Policy,
+		Func: func(p *interp.Interpreter, args []interp.Value) (interp.Value, error) {
+			re, err := compile(p, args[1])
+			if err != nil {
+				return nil, err
+			}
+			loc := re.FindStringIndex(string(args[0].(interp.String)))
+			if loc == nil {
+				return interp.Int(0), nil
+			}
+			return interp.Int(loc[0] + 1), nil
+		},
+	})
+	interp.RegisterBuiltin(&interp.Builtin{
+		Name:   "REPLACE$",
+		Syntax: "REPLACE$(a$, pattern$, repl$)",
+		Doc:    "a$ with every match of pattern$ replaced by repl$, in which $1 stands for the first group",
+		Params: []interp.Kind{interp.StringKind, interp.StringKind, interp.StringKind},
+		Result: interp.StringKind,
+		Policy: Policy,
+		Func: func(p *interp.Interpreter, args []interp.Value) (interp.Value, error) {
+			re, err := compile(p, args[1])
+			if err != nil {
+				return nil, err
+			}
+			s := re.ReplaceAllString(string(args[0].(interp.String)), string(args[2].(interp.String)))
+			return interp.String(s), nil
+		},
+	})
+}
+
+// compile returns the compiled pattern, reusing it if the program used it
+// before so that matching in a loop does not recompile it every time.
+func compile(p *interp.Interpreter, pattern interp.Value) (*regexp.Regexp, error) {
+	cache, _ := p.ExtData(cacheKey{}).(map[string]*regexp.Regexp)
+	if cache == nil || len(cache) >= maxCache {
+		cache = make(map[string]*regexp.Regexp)
+		p.SetExtData(cacheKey{}, cache)
+	}
+
+	s := string(pattern.(interp.String))
+	if re := cache[s]; re != nil {
+		return re, nil
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+	cache[s] = re
+	return re, nil
+}
@@ -0,0 +1,214 @@
+// Package fuzz generates random but well-formed programs directly as
+// ASTs and runs them through the interpreter, checking that no panic
+// escapes Eval and that every error reported carries a source position.
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/interp"
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+type Generator struct {
+	Rand  *rand.Rand
+	Lines int
+	Depth int
+	Vars  []string
+
+	labels []int64
+	line   int
+}
+
+func NewGenerator(seed int64) *Generator {
+	return &Generator{
+		Rand:  rand.New(rand.NewSource(seed)),
+		Lines: 32,
+		Depth: 4,
+		Vars:  []string{"a", "b", "c", "i", "j"},
+	}
+}
+
+// Program returns a program whose labels are strictly increasing and
+// whose GOTO/GOSUB targets all refer to labels within the program.
+func (g *Generator) Program() []ast.Stmt {
+	g.labels = g.labels[:0]
+	g.line = 0
+	for i := 0; i < g.Lines; i++ {
+		g.labels = append(g.labels, int64(i+1)*10)
+	}
+
+	var lines []ast.Stmt
+	for len(lines) < g.Lines {
+		lines = append(lines, g.stmt(len(lines), true)...)
+	}
+	return lines[:g.Lines]
+}
+
+func (g *Generator) pos() scanner.Position {
+	g.line++
+	return scanner.Position{Filename: "fuzz", Line: g.line, Column: 1}
+}
+
+func (g *Generator) label(i int) ast.Label {
+	return ast.Label{Pos: g.pos(), Value: g.labels[i]}
+}
+
+func (g *Generator) tok(typ lex.Token) ast.Token {
+	return ast.Token{Pos: scanner.Position{Filename: "fuzz", Line: g.line, Column: 4}, Type: typ}
+}
+
+func (g *Generator) variable() ast.Variable {
+	return ast.Variable{
+		Pos:  scanner.Position{Filename: "fuzz", Line: g.line, Column: 8},
+		Name: g.Vars[g.Rand.Intn(len(g.Vars))],
+	}
+}
+
+func (g *Generator) target() ast.Number {
+	return ast.Number{
+		Pos:   scanner.Position{Filename: "fuzz", Line: g.line, Column: 8},
+		Value: g.labels[g.Rand.Intn(len(g.labels))],
+	}
+}
+
+// stmt returns one or more statements starting at the i'th label. A FOR
+// statement is returned together with its matching NEXT so loops are
+// always properly nested.
+func (g *Generator) stmt(i int, top bool) []ast.Stmt {
+	base := ast.BaseStmt{Label: g.label(i)}
+	switch g.Rand.Intn(12) {
+	case 0:
+		return []ast.Stmt{&ast.GotoStmt{BaseStmt: base, Goto: g.tok(lex.GOTO), Location: g.target()}}
+	case 1:
+		return []ast.Stmt{&ast.GosubStmt{BaseStmt: base, Gosub: g.tok(lex.GOSUB), Location: g.target()}}
+	case 2:
+		return []ast.Stmt{&ast.ReturnStmt{BaseStmt: base, Return: g.tok(lex.RETURN)}}
+	case 3:
+		if !top || i+1 >= len(g.labels) {
+			break
+		}
+		v := g.variable()
+		next := g.label(i + 1)
+		return []ast.Stmt{
+			&ast.ForStmt{BaseStmt: base, For: g.tok(lex.FOR), Var: v, Start: g.expr(g.Depth), To: g.tok(lex.TO), End: g.expr(g.Depth)},
+			&ast.NextStmt{BaseStmt: ast.BaseStmt{Label: next}, Next: g.tok(lex.NEXT), Var: v},
+		}
+	case 4:
+		if !top {
+			break
+		}
+		s := &ast.IfStmt{BaseStmt: base, If: g.tok(lex.IF), Cond: g.relation(), Then: g.tok(lex.THEN)}
+		s.Body = g.stmt(i, false)[0]
+		if g.Rand.Intn(2) == 0 {
+			s.Else = &ast.ElseStmt{BaseStmt: base, Else: g.tok(lex.ELSE), Body: g.stmt(i, false)[0]}
+		}
+		return []ast.Stmt{s}
+	case 5:
+		return []ast.Stmt{&ast.PeekStmt{BaseStmt: base, Peek: g.tok(lex.PEEK), Addr: g.expr(g.Depth), Var: g.variable()}}
+	case 6:
+		return []ast.Stmt{&ast.PokeStmt{BaseStmt: base, Poke: g.tok(lex.POKE), Addr: g.expr(g.Depth), Value: g.expr(g.Depth)}}
+	case 7:
+		s := &ast.PrintStmt{BaseStmt: base, Print: g.tok(lex.PRINT)}
+		for n := g.Rand.Intn(4); n > 0; n-- {
+			s.Args = append(s.Args, g.expr(g.Depth), ast.Punct{Pos: g.tok(lex.SEMICOLON).Pos, Type: lex.SEMICOLON})
+		}
+		return []ast.Stmt{s}
+	case 8:
+		if g.Rand.Intn(4) == 0 {
+			return []ast.Stmt{&ast.EndStmt{BaseStmt: base, End: g.tok(lex.END)}}
+		}
+	}
+	return []ast.Stmt{&ast.LetStmt{BaseStmt: base, Let: g.tok(lex.LET), Var: g.variable(), Value: g.expr(g.Depth)}}
+}
+
+var (
+	arith = []lex.Token{lex.PLUS, lex.MINUS, lex.ASTR, lex.SLASH, lex.MOD, lex.AND, lex.OR, lex.XOR}
+	rel   = []lex.Token{lex.LT, lex.GT, lex.LEQ, lex.GEQ, lex.NEQ, lex.EQ}
+)
+
+func (g *Generator) relation() ast.Expr {
+	return &ast.BinaryExpr{
+		Op: g.tok(rel[g.Rand.Intn(len(rel))]),
+		X:  g.expr(g.Depth),
+		Y:  g.expr(g.Depth),
+	}
+}
+
+func (g *Generator) expr(depth int) ast.Expr {
+	if depth <= 0 {
+		if g.Rand.Intn(2) == 0 {
+			return g.variable()
+		}
+		return ast.Number{Pos: g.tok(lex.NUMBER).Pos, Value: g.Rand.Int63n(32) - 8}
+	}
+
+	switch g.Rand.Intn(4) {
+	case 0:
+		return &ast.BinaryExpr{
+			Op: g.tok(arith[g.Rand.Intn(len(arith))]),
+			X:  g.expr(depth - 1),
+			Y:  g.expr(depth - 1),
+		}
+	case 1:
+		return &ast.ParenExpr{Lparen: g.tok(lex.LPAREN), X: g.expr(depth - 1), Rparen: g.tok(lex.RPAREN)}
+	default:
+		return g.expr(0)
+	}
+}
+
+type mach struct {
+	values map[int64]int64
+}
+
+func (mach) Write(b []byte) (int, error) { return ioutil.Discard.Write(b) }
+func (m *mach) Peek(addr int64) int64    { return m.values[addr] }
+func (m *mach) Poke(addr, value int64)   { m.values[addr] = value }
+
+// Check runs lines for at most maxSteps statements. Runtime errors raised
+// by the program are expected; Check only reports a failure when a panic
+// escapes the interpreter or an error is returned without a position.
+func Check(lines []ast.Stmt, maxSteps int) (failure error) {
+	defer func() {
+		if e := recover(); e != nil {
+			failure = fmt.Errorf("panic escaped interpreter: %v", e)
+		}
+	}()
+
+	p := interp.NewInterpreter(&mach{values: make(map[int64]int64)})
+	p.Lines = lines
+	for i, s := range lines {
+		p.Locs[s.Line()] = i
+	}
+
+	for n := 0; n < maxSteps && !p.Halt; n++ {
+		err := p.Step()
+		if err == nil {
+			continue
+		}
+
+		var perr *ast.Error
+		if !errors.As(err, &perr) || !perr.Pos.IsValid() {
+			return fmt.Errorf("error without position: %v", err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// Fuzz generates and checks n programs, returning the first failure
+// together with the seed that reproduces it.
+func Fuzz(seed int64, n, maxSteps int) error {
+	for i := 0; i < n; i++ {
+		g := NewGenerator(seed + int64(i))
+		if err := Check(g.Program(), maxSteps); err != nil {
+			return fmt.Errorf("seed %d: %v", seed+int64(i), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package fuzz
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+func TestCheck(t *testing.T) {
+	n := 500
+	if testing.Short() {
+		n = 50
+	}
+	if err := Fuzz(1, n, 1000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// FuzzCompile compiles and runs mutations of the programs in testdata.
+// Programs that fail to compile are skipped; those that do must not
+// panic, and must only stop with errors that carry a position.
+func FuzzCompile(f *testing.F) {
+	const dir = "../testdata"
+	names, err := filepath.Glob(filepath.Join(dir, "*.bas"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range names {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		// Includes resolve inside testdata and nothing else can be opened.
+		prog, err := interp.CompileFiles(interp.DirFileSystem(dir), "fuzz.bas", src, "")
+		if err != nil {
+			return
+		}
+		p := interp.NewInterpreter(&mach{values: make(map[int64]int64)})
+		p.MaxSteps = 10000
+		p.Timeout = time.Second
+
+		defer func() {
+			if e := recover(); e != nil {
+				t.Fatalf("panic escaped interpreter: %v\n%s", e, src)
+			}
+		}()
+		err = p.Run(prog)
+		if err == nil || errors.Is(err, interp.ErrTimeLimit) {
+			return
+		}
+		var perr *ast.Error
+		if !errors.As(err, &perr) || !perr.Pos.IsValid() {
+			t.Fatalf("error without position: %v\n%s", err, src)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package interp
+
+import (
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// maxElems limits the size of arrays so that a program cannot exhaust the
+// host's memory with a single DIM.
+const maxElems = 1 << 24
+
+func (p *Interpreter) dim(s *ast.DimStmt) {
+	for _, d := range s.Arrays {
+		a := &Array{}
+		n := 1
+		for _, e := range d.Args {
+			max := p.int(e)
+			if max < 0 || max >= maxElems {
+				p.errf(ast.ExprPos(e), "dim: size %d out of range", max)
+			}
+			a.Dims = append(a.Dims, int(max)+1)
+			n *= int(max) + 1
+			if n > maxElems {
+				p.errf(d.Func.Pos, "dim: %v has more than %d elements", d.Func.Name, maxElems)
+			}
+		}
+		if len(a.Dims) == 0 {
+			p.errf(d.Lparen.Pos, "dim: %v needs at least one dimension", d.Func.Name)
+		}
+
+		zero := Zero(IntKind)
+		if strings.HasSuffix(d.Func.Name, "$") {
+			zero = Zero(StringKind)
+		}
+		a.Elems = make([]Value, n)
+		for i := range a.Elems {
+			a.Elems[i] = zero
+		}
+		p.setVar(d.Func.Name, a)
+	}
+}
+
+// element returns the position in a.Elems of the element at index.
+func (p *Interpreter) element(a *Array, v ast.Variable, index []ast.Expr) int {
+	if len(index) != len(a.Dims) {
+		p.errk(v.Pos, ErrIndex, "%v has %d dimensions, not %d", v.Name, len(a.Dims), len(index))
+	}
+	i := 0
+	for n, e := range index {
+		x := p.int(e)
+		if x < 0 || x >= int64(a.Dims[n]) {
+			p.errk(ast.ExprPos(e), ErrIndex, "index %d out of range for %v", x, v.Name)
+		}
+		i = i*a.Dims[n] + int(x)
+	}
+	return i
+}
@@ -0,0 +1,34 @@
+package interp
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "TRUE",
+		Syntax: "TRUE",
+		Doc:    "the value of a true condition, 1 or -1 depending on the truevalue option",
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return p.boolean(true), nil
+		},
+	})
+	RegisterBuiltin(&Builtin{
+		Name:   "FALSE",
+		Syntax: "FALSE",
+		Doc:    "the value of a false condition, 0",
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return p.boolean(false), nil
+		},
+	})
+}
+
+// boolean returns the value of a condition as produced by comparisons
+// and logical operators.
+func (p *Interpreter) boolean(x bool) Int {
+	switch {
+	case !x:
+		return 0
+	case p.TrueValue != 0:
+		return Int(p.TrueValue)
+	}
+	return 1
+}
@@ -0,0 +1,257 @@
+package interp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Builtin is a function callable from expressions. Params gives the kind
+// of each parameter, of which the last Optional may be omitted. Numeric
+// arguments are converted to the parameter kind before Func is called,
+// so Func only needs to handle the arguments it was actually given. Func
+// is passed the interpreter running the program for builtins that need
+// the machine.
+// Result is the kind of value Func returns, with FloatKind standing for
+// any number. A builtin with a Policy may only be called by interpreters
+// that allow it, so that builtins reaching outside the machine, such as
+// the network, are not available to programs by default. A Variadic
+// builtin takes any number of arguments of any kind after its Params.
+type Builtin struct {
+	Name     string
+	Syntax   string
+	Doc      string
+	Params   []Kind
+	Optional int
+	Variadic bool
+	Result   Kind
+	Policy   string
+	Func     func(p *Interpreter, args []Value) (Value, error)
+}
+
+// builtins holds the builtin functions by name. It is guarded by
+// builtinsMu, since interpreters running concurrently read it while
+// builtins may still be registered.
+var (
+	builtinsMu sync.RWMutex
+	builtins   = map[string]*Builtin{}
+)
+
+func init() {
+	for _, b := range []*Builtin{
+		{
+			Name:   "LEN",
+			Syntax: "LEN(s$)",
+			Doc:    "length of s$ in bytes",
+			Params: []Kind{StringKind},
+			Result: IntKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				return Int(len(args[0].(String))), nil
+			},
+		},
+		{
+			Name:     "MID$",
+			Syntax:   "MID$(s$, start[, n])",
+			Doc:      "n bytes of s$ from position start, counting from 1; the rest of s$ if n is omitted",
+			Params:   []Kind{StringKind, IntKind, IntKind},
+			Optional: 1,
+			Result:   StringKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				s, start := args[0].(String), args[1].(Int)
+				if start < 1 {
+					return nil, fmt.Errorf("start %d out of range", start)
+				}
+				n := Int(len(s))
+				if len(args) > 2 {
+					n = args[2].(Int)
+				}
+				if n < 0 {
+					return nil, fmt.Errorf("length %d out of range", n)
+				}
+				if start > Int(len(s)) {
+					return String(""), nil
+				}
+				s = s[start-1:]
+				if n < Int(len(s)) {
+					s = s[:n]
+				}
+				return s, nil
+			},
+		},
+		{
+			Name:   "LEFT$",
+			Syntax: "LEFT$(s$, n)",
+			Doc:    "the first n bytes of s$",
+			Params: []Kind{StringKind, IntKind},
+			Result: StringKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				s, n := args[0].(String), args[1].(Int)
+				if n < 0 {
+					return nil, fmt.Errorf("length %d out of range", n)
+				}
+				if n < Int(len(s)) {
+					s = s[:n]
+				}
+				return s, nil
+			},
+		},
+		{
+			Name:   "RIGHT$",
+			Syntax: "RIGHT$(s$, n)",
+			Doc:    "the last n bytes of s$",
+			Params: []Kind{StringKind, IntKind},
+			Result: StringKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				s, n := args[0].(String), args[1].(Int)
+				if n < 0 {
+					return nil, fmt.Errorf("length %d out of range", n)
+				}
+				if n < Int(len(s)) {
+					s = s[Int(len(s))-n:]
+				}
+				return s, nil
+			},
+		},
+		{
+			Name:     "INSTR",
+			Syntax:   "INSTR(s$, t$[, start])",
+			Doc:      "the position of the first t$ in s$ from position start, or 1 if it is omitted, counting from 1; 0 if there is none",
+			Params:   []Kind{StringKind, StringKind, IntKind},
+			Optional: 1,
+			Result:   IntKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				s, t, start := args[0].(String), args[1].(String), Int(1)
+				if len(args) > 2 {
+					start = args[2].(Int)
+				}
+				if start < 1 {
+					return nil, fmt.Errorf("start %d out of range", start)
+				}
+				if start > Int(len(s))+1 {
+					return Int(0), nil
+				}
+				i := strings.Index(string(s[start-1:]), string(t))
+				if i < 0 {
+					return Int(0), nil
+				}
+				return start + Int(i), nil
+			},
+		},
+		{
+			Name:   "CHR$",
+			Syntax: "CHR$(n)",
+			Doc:    "a one byte string holding the character code n",
+			Params: []Kind{IntKind},
+			Result: StringKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				n := args[0].(Int)
+				if n < 0 || n > 255 {
+					return nil, fmt.Errorf("character code %d out of range", n)
+				}
+				return String([]byte{byte(n)}), nil
+			},
+		},
+		{
+			Name:   "ASC",
+			Syntax: "ASC(s$)",
+			Doc:    "the character code of the first byte of s$",
+			Params: []Kind{StringKind},
+			Result: IntKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				s := args[0].(String)
+				if s == "" {
+					return nil, fmt.Errorf("empty string")
+				}
+				return Int(s[0]), nil
+			},
+		},
+		{
+			Name:   "VAL",
+			Syntax: "VAL(s$)",
+			Doc:    "the number written in s$, or 0 if s$ is not a number",
+			Params: []Kind{StringKind},
+			Result: FloatKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				s := strings.TrimSpace(string(args[0].(String)))
+				if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+					return Int(n), nil
+				}
+				if f, err := strconv.ParseFloat(s, 64); err == nil {
+					return Float(f), nil
+				}
+				return Int(0), nil
+			},
+		},
+		{
+			Name:   "STR$",
+			Syntax: "STR$(n)",
+			Doc:    "n formatted as a string the way PRINT writes it",
+			Params: []Kind{FloatKind},
+			Result: StringKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				return String(args[0].String()), nil
+			},
+		},
+	} {
+		builtins[b.Name] = b
+	}
+}
+
+// RegisterBuiltin adds b to the builtin functions. It is meant to be
+// called from the init function of extension packages, and panics if a
+// builtin with the same name already exists. A builtin registered while
+// a program runs takes precedence over a FUNCTION of the same name in it.
+func RegisterBuiltin(b *Builtin) {
+	builtinsMu.Lock()
+	defer builtinsMu.Unlock()
+	name := strings.ToUpper(b.Name)
+	if _, dup := builtins[name]; dup {
+		panic("interp: RegisterBuiltin called twice for " + name)
+	}
+	builtins[name] = b
+}
+
+// LookupBuiltin returns the builtin function with the given name.
+func LookupBuiltin(name string) (*Builtin, bool) {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+	b, ok := builtins[strings.ToUpper(name)]
+	return b, ok
+}
+
+// Builtins returns the names of all builtin functions in sorted order.
+func Builtins() []string {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// convert checks that v can be passed as a parameter of kind k. Floats
+// are truncated for integer parameters; numbers are passed unchanged to
+// float parameters so that STR$ of an integer does not gain a fraction.
+func convert(k Kind, v Value) (Value, error) {
+	switch k {
+	case IntKind:
+		n, err := AsInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return Int(n), nil
+	case FloatKind:
+		if !isNumber(v) {
+			return nil, fmt.Errorf("%w: expected number, got %v", ErrTypeMismatch, v.Kind())
+		}
+		return v, nil
+	}
+	if v.Kind() != k {
+		return nil, fmt.Errorf("%w: expected %v, got %v", ErrTypeMismatch, k, v.Kind())
+	}
+	return v, nil
+}
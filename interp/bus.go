@@ -0,0 +1,131 @@
+package interp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Device is hardware mapped into the memory of a BusMach, such as a UART
+// or a timer. Addresses are relative to the start of the range the
+// device is mapped at, so a device can be mapped anywhere.
+type Device interface {
+	Peek(addr int64) int64
+	Poke(addr, value int64)
+}
+
+// DeviceFuncs is a Device made of functions, for devices simple enough
+// not to need a type of their own. A nil PeekFunc reads 0 and a nil
+// PokeFunc ignores writes.
+type DeviceFuncs struct {
+	PeekFunc func(addr int64) int64
+	PokeFunc func(addr, value int64)
+}
+
+func (d DeviceFuncs) Peek(addr int64) int64 {
+	if d.PeekFunc == nil {
+		return 0
+	}
+	return d.PeekFunc(addr)
+}
+
+func (d DeviceFuncs) Poke(addr, value int64) {
+	if d.PokeFunc != nil {
+		d.PokeFunc(addr, value)
+	}
+}
+
+// BusMach is a machine whose memory is a map of devices at address
+// ranges, as on the boards old programs drove hardware from. PEEK and
+// POKE of an address in a mapped range go to the device there; all other
+// accesses and output go to the underlying Mach. Devices should be
+// mapped before programs run.
+type BusMach struct {
+	Mach
+	ranges []busRange
+}
+
+type busRange struct {
+	start, end int64
+	dev        Device
+}
+
+// NewBusMach returns a BusMach with no devices mapped that passes
+// everything through to mach.
+func NewBusMach(mach Mach) *BusMach {
+	return &BusMach{Mach: mach}
+}
+
+// Map maps dev at the addresses from start to end inclusive. The range
+// may not overlap one already mapped.
+func (b *BusMach) Map(start, end int64, dev Device) error {
+	if end < start {
+		return fmt.Errorf("bus: empty range %#x-%#x", start, end)
+	}
+	i := sort.Search(len(b.ranges), func(i int) bool { return b.ranges[i].end >= start })
+	if i < len(b.ranges) && b.ranges[i].start <= end {
+		r := b.ranges[i]
+		return fmt.Errorf("bus: range %#x-%#x overlaps %#x-%#x", start, end, r.start, r.end)
+	}
+	b.ranges = append(b.ranges, busRange{})
+	copy(b.ranges[i+1:], b.ranges[i:])
+	b.ranges[i] = busRange{start: start, end: end, dev: dev}
+	return nil
+}
+
+// device returns the device mapped at addr and its start address.
+func (b *BusMach) device(addr int64) (Device, int64, bool) {
+	i := sort.Search(len(b.ranges), func(i int) bool { return b.ranges[i].end >= addr })
+	if i < len(b.ranges) && b.ranges[i].start <= addr {
+		return b.ranges[i].dev, b.ranges[i].start, true
+	}
+	return nil, 0, false
+}
+
+func (b *BusMach) Peek(addr int64) int64 {
+	if dev, start, ok := b.device(addr); ok {
+		return dev.Peek(addr - start)
+	}
+	return b.Mach.Peek(addr)
+}
+
+func (b *BusMach) Poke(addr, value int64) {
+	if dev, start, ok := b.device(addr); ok {
+		dev.Poke(addr-start, value)
+		return
+	}
+	b.Mach.Poke(addr, value)
+}
+
+// PeekErr is like Peek, but passes on the errors of an underlying Mach
+// that is an ErrMach.
+func (b *BusMach) PeekErr(addr int64) (int64, error) {
+	if dev, start, ok := b.device(addr); ok {
+		return dev.Peek(addr - start), nil
+	}
+	if m, ok := b.Mach.(ErrMach); ok {
+		return m.PeekErr(addr)
+	}
+	return b.Mach.Peek(addr), nil
+}
+
+// PokeErr is like Poke, but passes on the errors of an underlying Mach
+// that is an ErrMach.
+func (b *BusMach) PokeErr(addr, value int64) error {
+	if dev, start, ok := b.device(addr); ok {
+		dev.Poke(addr-start, value)
+		return nil
+	}
+	if m, ok := b.Mach.(ErrMach); ok {
+		return m.PokeErr(addr, value)
+	}
+	b.Mach.Poke(addr, value)
+	return nil
+}
+
+// Flush flushes the underlying Mach if it buffers output.
+func (b *BusMach) Flush() error {
+	if m, ok := b.Mach.(FlushMach); ok {
+		return m.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package interp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// HostFunc is a function provided by the program embedding the
+// interpreter. The context is cancelled when the interpreter's is.
+type HostFunc func(ctx context.Context, args []Value) (Value, error)
+
+// Binding is a host function callable with CALL. If Async is set, the
+// function runs on its own goroutine; CALL then returns at once and a
+// WAIT statement joins on the result, unless Block is also set, in which
+// case CALL waits for the result itself. Either way, waiting can be
+// interrupted by cancelling the interpreter's context, so a slow host
+// operation does not freeze the step loop.
+type Binding struct {
+	Func  HostFunc
+	Async bool
+	Block bool
+}
+
+type asyncCall struct {
+	name string
+	pos  scanner.Position
+	done chan struct{}
+	v    Value
+	err  error
+}
+
+// Bind makes b callable from programs as CALL name and, unless it is
+// Async, as the function name(args) in expressions, where a nil result
+// is 0. Bindings take precedence over SUBs and FUNCTIONs of the same
+// name, but not over builtins.
+func (p *Interpreter) Bind(name string, b Binding) {
+	if p.bindings == nil {
+		p.bindings = make(map[string]*Binding)
+	}
+	p.bindings[strings.ToUpper(name)] = &b
+}
+
+func (p *Interpreter) callStmt(s *ast.CallStmt) {
+	name := strings.ToUpper(s.X.Func.Name)
+	b, ok := p.bindings[name]
+	if !ok {
+		loc, found := p.locateProc(name)
+		if !found {
+			p.errk(s.X.Func.Pos, ErrUndefinedFunction, "call: unknown procedure %v", s.X.Func.Name)
+		}
+		p.callProc(s, loc)
+		return
+	}
+
+	args := make([]Value, len(s.X.Args))
+	for i, a := range s.X.Args {
+		args[i] = p.expr(a)
+	}
+
+	if !b.Async {
+		if _, err := p.hostCall(Effect{Kind: "call", Name: name}, b.Func, args); err != nil {
+			p.errf(s.X.Func.Pos, "%s: %w", name, err)
+		}
+		return
+	}
+
+	c := &asyncCall{
+		name: name,
+		pos:  s.X.Func.Pos,
+		done: make(chan struct{}),
+	}
+	// A call being replayed is not made; join takes its result from the
+	// journal.
+	if j := p.journal; j != nil && j.replay {
+		close(c.done)
+	} else {
+		go func(ctx context.Context) {
+			defer close(c.done)
+			c.v, c.err = b.Func(ctx, args)
+		}(p.ctx())
+	}
+
+	if b.Block {
+		p.join(c)
+		return
+	}
+	p.pending = append(p.pending, c)
+}
+
+func (p *Interpreter) wait(s *ast.WaitStmt) {
+	name := strings.ToUpper(s.Name.Name)
+	var calls, pending []*asyncCall
+	for _, c := range p.pending {
+		if name == "" || c.name == name {
+			calls = append(calls, c)
+		} else {
+			pending = append(pending, c)
+		}
+	}
+	p.pending = pending
+
+	var last *asyncCall
+	for _, c := range calls {
+		p.join(c)
+		last = c
+	}
+
+	if s.Var != nil {
+		if last == nil {
+			p.errf(s.Wait.Pos, "wait: no calls to %v are pending", s.Name.Name)
+		}
+		v := last.v
+		if v == nil {
+			v = Int(0)
+		}
+		p.setVar(s.Var.Name, v)
+	}
+}
+
+// join waits for c to finish, reporting its error at the CALL that
+// started it. Its result is an effect, logged when it is joined so that
+// calls finishing in any order are replayed in the order they were used.
+func (p *Interpreter) join(c *asyncCall) {
+	e, err := p.effect(Effect{Kind: "call", Name: c.name}, func(e *Effect) error {
+		select {
+		case <-c.done:
+		case <-p.ctx().Done():
+			return p.ctx().Err()
+		}
+		e.setResult(c.v)
+		return c.err
+	})
+	if err != nil {
+		p.errf(c.pos, "%s: %w", c.name, err)
+	}
+	c.v = e.result()
+}
+
+// hostCall calls fn with args as the effect e, so that calls to the host
+// are journaled like the other effects on a program.
+func (p *Interpreter) hostCall(e Effect, fn HostFunc, args []Value) (Value, error) {
+	e, err := p.effect(e, func(e *Effect) error {
+		v, err := fn(p.ctx(), args)
+		e.setResult(v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.result(), nil
+}
+
+// RegisterFunc binds fn, as Bind does, as a function taking and
+// returning integers, name(args), which CALL name(args) calls discarding
+// the result. Arguments are truncated to integers. It replaces any
+// binding of the same name. Unlike builtins, such functions belong to
+// one interpreter, so DryRun does not know of them. RegisterFunc panics
+// if name is that of a builtin.
+func (p *Interpreter) RegisterFunc(name string, fn func(args []int64) (int64, error)) {
+	name = strings.ToUpper(name)
+	if _, ok := LookupBuiltin(name); ok {
+		panic("interp: RegisterFunc called for the builtin " + name)
+	}
+	p.Bind(name, Binding{Func: func(ctx context.Context, args []Value) (Value, error) {
+		ns := make([]int64, len(args))
+		for i, a := range args {
+			n, err := AsInt(a)
+			if err != nil {
+				return nil, err
+			}
+			ns[i] = n
+		}
+		n, err := fn(ns)
+		return Int(n), err
+	}})
+}
+
+// hostFunc calls the function bound for e in an expression, reporting
+// whether there is one.
+func (p *Interpreter) hostFunc(e *ast.CallExpr) (Value, bool) {
+	name := strings.ToUpper(e.Func.Name)
+	b, ok := p.bindings[name]
+	if !ok {
+		return nil, false
+	}
+	if b.Async {
+		p.errf(e.Func.Pos, "%s: asynchronous calls have no value; use CALL and WAIT", name)
+	}
+	args := make([]Value, len(e.Args))
+	for i, a := range e.Args {
+		args[i] = p.expr(a)
+	}
+	v, err := p.hostCall(Effect{Kind: "call", Name: name}, b.Func, args)
+	if err != nil {
+		p.errf(e.Func.Pos, "%s: %w", name, err)
+	}
+	if v == nil {
+		v = Int(0)
+	}
+	return p.wrap(v), true
+}
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:     "USR",
+		Syntax:   "USR(addr [, arg] ...)",
+		Doc:      "the result of calling the host routine bound to addr with the args",
+		Params:   []Kind{IntKind},
+		Variadic: true,
+		Result:   anyKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			v, err := p.routine(int64(args[0].(Int)), args[1:])
+			if v == nil && err == nil {
+				v = Int(0)
+			}
+			return v, err
+		},
+	})
+}
+
+// BindRoutine makes f callable from programs as SYS addr, which discards
+// its result, and USR(addr), which returns it, the way programs for old
+// machines called routines in machine code. A nil result is 0 to USR.
+func (p *Interpreter) BindRoutine(addr int64, f HostFunc) {
+	if p.routines == nil {
+		p.routines = make(map[int64]HostFunc)
+	}
+	p.routines[addr] = f
+}
+
+func (p *Interpreter) routine(addr int64, args []Value) (Value, error) {
+	f, ok := p.routines[addr]
+	if !ok {
+		return nil, fmt.Errorf("no routine at %d", addr)
+	}
+	return p.hostCall(Effect{Kind: "sys", Arg: addr}, f, args)
+}
+
+func (p *Interpreter) sys(s *ast.SysStmt) {
+	addr := p.int(s.Addr)
+	args := make([]Value, len(s.Args))
+	for i, a := range s.Args {
+		args[i] = p.expr(a)
+	}
+	if _, err := p.routine(addr, args); err != nil {
+		p.errf(s.Sys.Pos, "sys: %w", err)
+	}
+}
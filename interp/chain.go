@@ -0,0 +1,78 @@
+package interp
+
+import (
+	"io/ioutil"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// chain replaces the program with the one named by s. Open files are
+// kept so that programs in a suite can pass data through them, as are
+// variables if ALL is given; everything else is reset as by RUN. Like
+// RUN, it cannot be used inside a SUB or FUNCTION.
+func (p *Interpreter) chain(s *ast.ChainStmt) {
+	if sub := p.inside(); sub != "" {
+		p.errf(s.Chain.Pos, "chain: not allowed inside %s", sub)
+	}
+	name, ok := p.expr(s.Name).(String)
+	if !ok {
+		p.errf(ast.ExprPos(s.Name), "chain: %w: expected file name", ErrTypeMismatch)
+	}
+	if p.Files == nil {
+		p.errf(s.Chain.Pos, "chain: file access is not allowed")
+	}
+	src, err := readProgram(p.Files, string(name))
+	if err != nil {
+		p.errf(ast.ExprPos(s.Name), "chain: %w", err)
+	}
+	// Errors in the chained program are reported at their own position.
+	prog, err := CompileFiles(p.Files, string(name), src, "")
+	if err != nil {
+		panic(err)
+	}
+
+	if s.Location != nil && !hasLine(prog, s.Location.Value) {
+		p.errk(s.Location.Pos, ErrBadGoto, "chain: location %d does not exist in %s", s.Location.Value, name)
+	}
+
+	vars, files := p.Vars, p.files
+	p.Lines = prog.Lines
+	p.relink()
+	p.stream = nil
+	p.files = nil
+	p.Reset()
+	p.files = files
+	p.configure(prog)
+	if s.All != nil {
+		p.Vars = vars
+	}
+	if s.Location != nil {
+		p.PC = p.Locs[s.Location.Value]
+	}
+}
+
+// hasLine reports whether prog has a line with the given number.
+func hasLine(prog *Program, line int64) bool {
+	for _, l := range prog.Lines {
+		if l.Line() == line {
+			return true
+		}
+	}
+	return false
+}
+
+// readProgram reads the named program from files, decompressing it if
+// needed.
+func readProgram(files FileSystem, name string) ([]byte, error) {
+	f, err := files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,50 @@
+package interp
+
+import "time"
+
+// Clock is the source of time for TIMER, TIME and TIME$. Embedders and
+// tests can set Interpreter.Clock to control what programs see.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "TIMER",
+		Syntax: "TIMER",
+		Doc:    "milliseconds elapsed since the program started",
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return Int(p.now().Sub(p.start) / time.Millisecond), nil
+		},
+	})
+	RegisterBuiltin(&Builtin{
+		Name:   "TIME",
+		Syntax: "TIME",
+		Doc:    "the wall clock time in seconds since 1970-01-01 UTC",
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return Int(p.now().Unix()), nil
+		},
+	})
+	RegisterBuiltin(&Builtin{
+		Name:   "TIME$",
+		Syntax: "TIME$",
+		Doc:    "the local wall clock time as HH:MM:SS",
+		Result: StringKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return String(p.now().Format("15:04:05")), nil
+		},
+	})
+}
+
+func (p *Interpreter) now() time.Time {
+	if p.Clock == nil {
+		return time.Now()
+	}
+	return p.Clock.Now()
+}
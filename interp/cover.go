@@ -0,0 +1,57 @@
+package interp
+
+import "github.com/qeedquan/go-ubasic/ast"
+
+// Coverage records the lines of a program executed at least once, to
+// measure how much of a program its tests exercise and to find code that
+// never runs. Set it as the Coverage of an interpreter to record the
+// programs it runs. Unlike a Tracer, it also sees the lines of FUNCTIONs
+// called from expressions.
+type Coverage struct {
+	hit map[int64]bool
+}
+
+// NewCoverage returns a Coverage that has recorded nothing yet.
+func NewCoverage() *Coverage {
+	return &Coverage{hit: make(map[int64]bool)}
+}
+
+// Executed reports whether the line numbered n was executed.
+func (c *Coverage) Executed(n int64) bool {
+	return c.hit[n]
+}
+
+// Reset forgets the lines recorded so far.
+func (c *Coverage) Reset() {
+	c.hit = make(map[int64]bool)
+}
+
+// Count returns the number of lines executed and the total number of
+// lines in a program.
+func (c *Coverage) Count(lines []ast.Stmt) (executed, total int) {
+	for _, s := range lines {
+		if c.hit[s.Line()] {
+			executed++
+		}
+	}
+	return executed, len(lines)
+}
+
+// Missed returns the numbers of the lines of a program never executed, in
+// the order they appear in it.
+func (c *Coverage) Missed(lines []ast.Stmt) []int64 {
+	var missed []int64
+	for _, s := range lines {
+		if !c.hit[s.Line()] {
+			missed = append(missed, s.Line())
+		}
+	}
+	return missed
+}
+
+// cover records that s was executed.
+func (p *Interpreter) cover(s ast.Stmt) {
+	if p.Coverage != nil {
+		p.Coverage.hit[s.Line()] = true
+	}
+}
@@ -0,0 +1,66 @@
+package interp
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "RND",
+		Syntax: "RND(n)",
+		Doc:    "a random integer from 0 to n-1",
+		Params: []Kind{IntKind},
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			n := int64(args[0].(Int))
+			if n <= 0 {
+				return nil, fmt.Errorf("%d out of range", n)
+			}
+			e, err := p.effect(Effect{Kind: "rnd", Arg: n}, func(e *Effect) error {
+				if p.Rand == nil {
+					e.Value = rand.Int63n(n)
+				} else {
+					e.Value = p.Rand.Int63n(n)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			return Int(e.Value), nil
+		},
+	})
+}
+
+// FixedClock is a Clock that is stopped at a time, so that TIMER is
+// always 0 and TIME and TIME$ never change.
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c) }
+
+// Deterministic holds the settings that make a program behave the same
+// on every run, so that its output can be compared byte for byte with a
+// known good copy. Programs that read INPUT or devices are made to do so
+// by replaying a journal of their effects with ReplayEffects.
+type Deterministic struct {
+	// Seed is the seed of the random numbers RND returns.
+	Seed int64
+
+	// Time is the time the clock is stopped at. If it is zero,
+	// 2000-01-01 00:00:00 UTC is used.
+	Time time.Time
+}
+
+// SetDeterministic applies d to the interpreter. It should be called
+// before the program runs.
+func (p *Interpreter) SetDeterministic(d Deterministic) {
+	t := d.Time
+	if t.IsZero() {
+		t = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	p.Rand = rand.New(rand.NewSource(d.Seed))
+	p.Clock = FixedClock(t)
+	p.start = t
+}
@@ -0,0 +1,598 @@
+package interp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// anyKind is the kind of an expression whose kind cannot be known without
+// running the program.
+const anyKind Kind = -1
+
+// dryRun holds the state of a DryRun pass.
+type dryRun struct {
+	lines map[int64]bool
+	names map[string]bool
+	procs map[string]*proc
+	vars  map[string]Kind
+	errs  []error
+
+	changed bool
+}
+
+// DryRun walks prog without executing it, simulating the kinds of values
+// variables hold, and reports probable errors: operators applied to
+// values of the wrong kind, bad builtin calls, variables that are read but
+// never assigned, jumps to missing lines, calls of SUBs with the wrong
+// number of arguments, unbalanced FOR/NEXT, WHILE/WEND and SUB/END SUB
+// and line numbers used twice. Since no statement is executed it may
+// report problems on paths the program never takes.
+func DryRun(prog *Program) []error {
+	d := newDryRun(prog.Lines)
+
+	// A variable's kind is the kind of every value assigned to it anywhere
+	// in the program, so reads can be checked regardless of order. Values
+	// may be computed from other variables, so assignments are revisited
+	// until no more kinds are learned before conflicts are reported.
+	var stmts []ast.Stmt
+	for _, s := range prog.Lines {
+		stmts = appendStmts(stmts, s)
+	}
+	for d.changed = true; d.changed; {
+		d.changed = false
+		for _, s := range stmts {
+			d.assigns(s, false)
+		}
+	}
+	for _, s := range stmts {
+		d.assigns(s, true)
+	}
+
+	d.blocks(prog.Lines)
+	for _, s := range stmts {
+		d.stmt(s)
+		d.jump(s)
+	}
+	return d.sorted()
+}
+
+// Check verifies the structure of the program made of lines before it
+// runs, so that mistakes surface at once rather than when the statement
+// with them is reached: that line numbers are not used twice, that the
+// lines and labels jumped to exist, that WHILE/WEND and SUB/END SUB are
+// balanced and that each FOR has a NEXT and each NEXT a FOR. Unlike DryRun it does not look at the kinds
+// of values, so whatever it reports is certain to be wrong.
+func Check(lines []ast.Stmt) []error {
+	d := newDryRun(lines)
+	d.blocks(lines)
+	for _, s := range lines {
+		for _, s := range appendStmts(nil, s) {
+			d.jump(s)
+		}
+	}
+	return d.sorted()
+}
+
+// newDryRun returns a dryRun for the program made of lines, knowing its
+// line numbers, labels and procedures, and reports numbers used twice.
+func newDryRun(lines []ast.Stmt) *dryRun {
+	d := &dryRun{
+		lines: make(map[int64]bool),
+		names: make(map[string]bool),
+		procs: make(map[string]*proc),
+		vars:  make(map[string]Kind),
+	}
+	for _, s := range lines {
+		switch l := s.(type) {
+		case *ast.LabelStmt:
+			d.names[l.Name.Name] = true
+			if !l.Numbered {
+				continue
+			}
+		case *ast.SubStmt, *ast.FunctionStmt:
+			pr := procOf(l)
+			d.procs[strings.ToUpper(pr.name.Name)] = pr
+		}
+		if d.lines[s.Line()] {
+			d.errf(s.Pos(), "line %d is used twice", s.Line())
+		}
+		d.lines[s.Line()] = true
+	}
+	return d
+}
+
+// blocks checks that WHILE/WEND and SUB/END SUB are balanced. FOR and
+// NEXT need not be, since a loop may have a NEXT on each of several
+// branches and NEXT is matched against the loops running, so it only
+// checks that some FOR could be ended by each NEXT, and the reverse.
+func (d *dryRun) blocks(lines []ast.Stmt) {
+	var fors []*ast.ForStmt
+	var nexts []*ast.NextStmt
+	var whiles []*ast.WhileStmt
+	var open *proc
+	for _, s := range lines {
+		switch s := s.(type) {
+		case *ast.SubStmt, *ast.FunctionStmt:
+			if open != nil {
+				d.errf(open.stmt.Pos(), "%s %v without end %s", open.kw(), open.name.Name, open.kw())
+			}
+			open = procOf(s)
+		case *ast.EndSubStmt:
+			if open == nil || open.fn {
+				d.errf(s.End.Pos, "end sub without sub")
+			}
+			open = nil
+		case *ast.EndFunctionStmt:
+			if open == nil || !open.fn {
+				d.errf(s.End.Pos, "end function without function")
+			}
+			open = nil
+		case *ast.ForStmt:
+			fors = append(fors, s)
+		case *ast.NextStmt:
+			nexts = append(nexts, s)
+		case *ast.WhileStmt:
+			whiles = append(whiles, s)
+		case *ast.WendStmt:
+			if len(whiles) == 0 {
+				d.errf(s.Wend.Pos, "wend without while")
+			} else {
+				whiles = whiles[:len(whiles)-1]
+			}
+		}
+	}
+	looped := make(map[string]bool)
+	for _, s := range fors {
+		looped[s.Var.Name] = true
+	}
+	named := make(map[string]bool)
+	for _, s := range nexts {
+		named[s.Var.Name] = true
+		if len(fors) == 0 || s.Var.Name != "" && !looped[s.Var.Name] {
+			d.errf(s.Next.Pos, "next %v without for", s.Var.Name)
+		}
+	}
+	for _, s := range fors {
+		if !named[s.Var.Name] && !named[""] {
+			d.errf(s.For.Pos, "for %v without next", s.Var.Name)
+		}
+	}
+	for _, s := range whiles {
+		d.errf(s.While.Pos, "while without wend")
+	}
+	if open != nil {
+		d.errf(open.stmt.Pos(), "%s %v without end %s", open.kw(), open.name.Name, open.kw())
+	}
+}
+
+// sorted returns the errors found in the order of their positions.
+func (d *dryRun) sorted() []error {
+	sort.SliceStable(d.errs, func(i, j int) bool {
+		a, b := d.errs[i].(*ast.Error).Pos, d.errs[j].(*ast.Error).Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return d.errs
+}
+
+// appendStmts appends s and the statements nested in it to list.
+func appendStmts(list []ast.Stmt, s ast.Stmt) []ast.Stmt {
+	if s == nil {
+		return list
+	}
+	list = append(list, s)
+	if s, ok := s.(*ast.IfStmt); ok {
+		list = appendStmts(list, s.Body)
+		for _, e := range s.ElseIf {
+			list = appendStmts(list, e.Body)
+		}
+		if s.Else != nil {
+			list = appendStmts(list, s.Else.Body)
+		}
+	}
+	return list
+}
+
+func (d *dryRun) errf(pos scanner.Position, format string, args ...interface{}) {
+	d.errs = append(d.errs, &ast.Error{Pos: pos, Err: fmt.Errorf(format, args...)})
+}
+
+// assigns records the kind of the value s assigns, if any.
+func (d *dryRun) assigns(s ast.Stmt, report bool) {
+	switch s := s.(type) {
+	case *ast.LetStmt:
+		if s.Index == nil {
+			d.assign(s.Var, d.expr(s.Value, false), report)
+		}
+	case *ast.DimStmt:
+		for _, a := range s.Arrays {
+			d.assign(a.Func, ArrayKind, report)
+		}
+	case *ast.ForStmt:
+		d.assign(s.Var, IntKind, report)
+	case *ast.SubStmt, *ast.FunctionStmt:
+		for _, v := range procOf(s).params {
+			d.assign(v, anyKind, report)
+		}
+	case *ast.WaitStmt:
+		if s.Var != nil {
+			d.assign(*s.Var, anyKind, report)
+		}
+	case *ast.GetStmt:
+		if strings.HasSuffix(s.Var.Name, "$") {
+			d.assign(s.Var, StringKind, report)
+		} else {
+			d.assign(s.Var, IntKind, report)
+		}
+	case *ast.InputStmt, *ast.PeekStmt, *ast.LocalStmt:
+		var vars []ast.Variable
+		switch s := s.(type) {
+		case *ast.InputStmt:
+			vars = s.Vars
+		case *ast.PeekStmt:
+			vars = append(vars, s.Var)
+		case *ast.LocalStmt:
+			vars = s.Vars
+		}
+		for _, v := range vars {
+			if _, ok := s.(*ast.PeekStmt); !ok && strings.HasSuffix(v.Name, "$") {
+				d.assign(v, StringKind, report)
+			} else {
+				d.assign(v, IntKind, report)
+			}
+		}
+	}
+}
+
+func (d *dryRun) assign(v ast.Variable, k Kind, report bool) {
+	if isNumberKind(k) {
+		k = FloatKind
+	}
+	old, seen := d.vars[v.Name]
+	switch {
+	case !seen || old == anyKind && k != anyKind:
+		d.vars[v.Name] = k
+		d.changed = true
+	case old == k || k == anyKind:
+	case report:
+		d.errf(v.Pos, "%v is assigned both %v and %v values", v.Name, kindName(old), kindName(k))
+	}
+}
+
+func (d *dryRun) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.LetStmt:
+		for _, e := range s.Index {
+			d.number(e)
+		}
+		if s.Index != nil && d.vars[s.Var.Name] != ArrayKind {
+			d.errf(s.Var.Pos, "%v is not an array", s.Var.Name)
+		}
+		k := d.expr(s.Value, true)
+		if strings.HasSuffix(s.Var.Name, "$") != (k == StringKind) && k != anyKind {
+			d.errf(s.Var.Pos, "%v assigned a %v value", s.Var.Name, kindName(k))
+		}
+	case *ast.PrintStmt:
+		if s.Channel != nil {
+			d.number(s.Channel)
+		}
+		for _, arg := range s.Args {
+			if c, ok := arg.(*ast.CallExpr); ok {
+				switch strings.ToUpper(c.Func.Name) {
+				case "TAB", "SPC":
+					for _, a := range c.Args {
+						d.number(a)
+					}
+					continue
+				}
+			}
+			if _, ok := arg.(ast.Punct); !ok {
+				d.expr(arg, true)
+			}
+		}
+	case *ast.IfStmt:
+		d.number(s.Cond)
+		for _, e := range s.ElseIf {
+			d.number(e.Cond)
+		}
+	case *ast.WhileStmt:
+		d.number(s.Cond)
+	case *ast.ForStmt:
+		d.number(s.Start)
+		d.number(s.End)
+	case *ast.DimStmt:
+		for _, a := range s.Arrays {
+			for _, e := range a.Args {
+				d.number(e)
+			}
+		}
+	case *ast.PlotStmt:
+		vars := []ast.Variable{s.X}
+		if s.Y != nil {
+			vars = append(vars, *s.Y)
+		}
+		for _, v := range vars {
+			if d.vars[v.Name] != ArrayKind || strings.HasSuffix(v.Name, "$") {
+				d.errf(v.Pos, "plot: %v is not a numeric array", v.Name)
+			}
+		}
+	case *ast.LocateStmt:
+		d.number(s.Row)
+		d.number(s.Col)
+	case *ast.ColorStmt:
+		d.number(s.Fg)
+		if s.Bg != nil {
+			d.number(s.Bg)
+		}
+	case *ast.InputStmt:
+		if s.Channel != nil {
+			d.number(s.Channel)
+		}
+	case *ast.OpenStmt:
+		if isNumberKind(d.expr(s.Name, true)) {
+			d.errf(ast.ExprPos(s.Name), "open: file name is a number")
+		}
+		d.number(s.Channel)
+	case *ast.SysStmt:
+		d.number(s.Addr)
+		for _, a := range s.Args {
+			d.expr(a, true)
+		}
+	case *ast.ChainStmt:
+		if isNumberKind(d.expr(s.Name, true)) {
+			d.errf(ast.ExprPos(s.Name), "chain: file name is a number")
+		}
+	case *ast.CloseStmt:
+		for _, e := range s.Channels {
+			d.number(e)
+		}
+	case *ast.PeekStmt:
+		d.number(s.Addr)
+	case *ast.PokeStmt:
+		d.number(s.Addr)
+		d.number(s.Value)
+	case *ast.ReturnStmt:
+		if s.Value != nil {
+			d.expr(s.Value, true)
+		}
+	case *ast.CallStmt:
+		for _, a := range s.X.Args {
+			d.expr(a, true)
+		}
+		d.args(s.X)
+	case *ast.GotoStmt:
+		if s.Expr != nil {
+			d.expr(s.Expr, true)
+		}
+	case *ast.GosubStmt:
+		if s.Expr != nil {
+			d.expr(s.Expr, true)
+		}
+	}
+}
+
+// jump checks that the line or label s jumps to, if any, exists.
+func (d *dryRun) jump(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.GotoStmt:
+		if s.Expr == nil {
+			d.target(s.Location, s.Target)
+		}
+	case *ast.GosubStmt:
+		if s.Expr == nil {
+			d.target(s.Location, s.Target)
+		}
+	case *ast.OnErrorStmt:
+		if s.Target.Name != "" || s.Location.Value != 0 {
+			d.target(s.Location, s.Target)
+		}
+	case *ast.ResumeStmt:
+		if s.Location != nil {
+			d.target(*s.Location, s.Target)
+		} else if s.Target.Name != "" {
+			d.target(ast.Number{}, s.Target)
+		}
+	case *ast.RunStmt:
+		if s.Location != nil || s.Target.Name != "" {
+			var line ast.Number
+			if s.Location != nil {
+				line = *s.Location
+			}
+			d.target(line, s.Target)
+		}
+	}
+}
+
+// proc is a SUB or FUNCTION declared in the program.
+type proc struct {
+	stmt   ast.Stmt
+	name   ast.Variable
+	params []ast.Variable
+	fn     bool
+}
+
+func procOf(s ast.Stmt) *proc {
+	switch s := s.(type) {
+	case *ast.SubStmt:
+		return &proc{stmt: s, name: s.Name, params: s.Params}
+	case *ast.FunctionStmt:
+		return &proc{stmt: s, name: s.Name, params: s.Params, fn: true}
+	}
+	return nil
+}
+
+func (pr *proc) kw() string {
+	if pr.fn {
+		return "function"
+	}
+	return "sub"
+}
+
+// args checks the number of arguments of a call to a SUB or FUNCTION.
+func (d *dryRun) args(e *ast.CallExpr) {
+	pr, ok := d.procs[strings.ToUpper(e.Func.Name)]
+	if ok && len(e.Args) != len(pr.params) {
+		d.errf(e.Func.Pos, "%v takes %d arguments, got %d", pr.name.Name, len(pr.params), len(e.Args))
+	}
+}
+
+func (d *dryRun) target(n ast.Number, name ast.Variable) {
+	switch {
+	case name.Name != "":
+		if !d.names[name.Name] {
+			d.errf(name.Pos, "label %v does not exist", name.Name)
+		}
+	case !d.lines[n.Value]:
+		d.errf(n.Pos, "line %d does not exist", n.Value)
+	}
+}
+
+// number checks that e is numeric.
+func (d *dryRun) number(e ast.Expr) {
+	if k := d.expr(e, true); k == StringKind {
+		d.errf(ast.ExprPos(e), "expected number, got string")
+	}
+}
+
+// expr returns the kind of e. Errors are only reported if report is set,
+// so that expressions can be typed while collecting assignments without
+// reporting their errors twice.
+func (d *dryRun) expr(e ast.Expr, report bool) Kind {
+	errf := d.errf
+	if !report {
+		errf = func(scanner.Position, string, ...interface{}) {}
+	}
+
+	switch e := e.(type) {
+	case ast.Number:
+		return IntKind
+	case ast.String:
+		return StringKind
+	case ast.Variable:
+		k, ok := d.vars[e.Name]
+		if b, isFunc := LookupBuiltin(e.Name); !ok && isFunc && len(b.Params) == b.Optional {
+			return b.Result
+		}
+		if !ok {
+			errf(e.Pos, "%v is never assigned", e.Name)
+			return anyKind
+		}
+		return k
+	case *ast.ParenExpr:
+		return d.expr(e.X, report)
+	case *ast.UnaryExpr:
+		k := d.expr(e.X, report)
+		if k == StringKind {
+			errf(e.Op.Pos, "operator %q is not defined on strings", e.Op.Type)
+		}
+		if e.Op.Type == lex.NOT || e.Op.Type == lex.TILDE {
+			return IntKind
+		}
+		return k
+	case *ast.BinaryExpr:
+		x, y := d.expr(e.X, report), d.expr(e.Y, report)
+		if x == anyKind || y == anyKind {
+			return anyKind
+		}
+		if (x == StringKind) != (y == StringKind) {
+			errf(e.Op.Pos, "operator %q applied to %v and %v", e.Op.Type, kindName(x), kindName(y))
+			return anyKind
+		}
+		switch e.Op.Type {
+		case lex.LT, lex.GT, lex.LEQ, lex.GEQ, lex.NEQ, lex.EQ, lex.LAND, lex.LOR:
+			return IntKind
+		case lex.PLUS:
+			return x
+		}
+		if x == StringKind {
+			errf(e.Op.Pos, "operator %q is not defined on strings", e.Op.Type)
+			return anyKind
+		}
+		return FloatKind
+	case *ast.CallExpr:
+		if d.vars[e.Func.Name] == ArrayKind {
+			for _, a := range e.Args {
+				if d.expr(a, report) == StringKind {
+					errf(ast.ExprPos(a), "index of %v is a string", e.Func.Name)
+				}
+			}
+			if strings.HasSuffix(e.Func.Name, "$") {
+				return StringKind
+			}
+			return FloatKind
+		}
+		b, ok := LookupBuiltin(e.Func.Name)
+		if pr, isProc := d.procs[strings.ToUpper(e.Func.Name)]; !ok && isProc {
+			for _, a := range e.Args {
+				d.expr(a, report)
+			}
+			if !pr.fn {
+				errf(e.Func.Pos, "%v is a sub, not a function", e.Func.Name)
+			} else if report {
+				d.args(e)
+			}
+			if strings.HasSuffix(pr.name.Name, "$") {
+				return StringKind
+			}
+			return anyKind
+		}
+		if !ok {
+			errf(e.Func.Pos, "unknown function %v", e.Func.Name)
+			return anyKind
+		}
+		if n := len(e.Args); n < len(b.Params)-b.Optional || n > len(b.Params) && !b.Variadic {
+			errf(e.Lparen.Pos, "%s: wrong number of arguments, expected %s", b.Name, b.Syntax)
+			return b.Result
+		}
+		if isIIF(e) {
+			return d.iif(e, report)
+		}
+		for i, a := range e.Args {
+			k := d.expr(a, report)
+			if i >= len(b.Params) {
+				continue
+			}
+			if k != anyKind && (k == StringKind) != (b.Params[i] == StringKind) {
+				errf(ast.ExprPos(a), "%s: expected %v, got %v", b.Name, kindName(b.Params[i]), kindName(k))
+			}
+		}
+		return b.Result
+	}
+	return anyKind
+}
+
+// iif checks IIF(cond, a, b), whose kind is that of a and b if they
+// agree.
+func (d *dryRun) iif(e *ast.CallExpr, report bool) Kind {
+	if d.expr(e.Args[0], report) == StringKind && report {
+		d.errf(ast.ExprPos(e.Args[0]), "IIF: expected number, got string")
+	}
+	a, b := d.expr(e.Args[1], report), d.expr(e.Args[2], report)
+	switch {
+	case a == b:
+		return a
+	case isNumberKind(a) && isNumberKind(b):
+		return FloatKind
+	}
+	return anyKind
+}
+
+func isNumberKind(k Kind) bool {
+	return k == IntKind || k == FloatKind
+}
+
+// kindName describes k as DryRun sees it, where ints and floats are
+// simply numbers.
+func kindName(k Kind) string {
+	switch {
+	case isNumberKind(k):
+		return "number"
+	case k == anyKind:
+		return "unknown"
+	}
+	return k.String()
+}
@@ -0,0 +1,72 @@
+package interp
+
+import "github.com/qeedquan/go-ubasic/ast"
+
+// SetLine replaces the line numbered s.Line() with s, or inserts s before
+// the first line with a greater number if there is none, while the
+// program is paused between statements. The program carries on from the
+// same place: PC, open FOR and WHILE loops, GOSUB and call returns and
+// the ON ERROR handler keep referring to the statements they did, so a
+// line inserted after a FOR or GOSUB is part of the loop or runs on
+// return. A replaced line takes effect the next time it is reached,
+// including a retry by Continue after it failed.
+func (p *Interpreter) SetLine(s ast.Stmt) {
+	lines := make([]ast.Stmt, 0, len(p.Lines)+1)
+	if i, found := p.Locs[s.Line()]; found {
+		lines = append(lines, p.Lines...)
+		lines[i] = s
+		p.Lines = lines
+		p.relink()
+		return
+	}
+
+	i := 0
+	for i < len(p.Lines) && p.Lines[i].Line() <= s.Line() {
+		i++
+	}
+	lines = append(lines, p.Lines[:i]...)
+	lines = append(lines, s)
+	p.Lines = append(lines, p.Lines[i:]...)
+	p.moveIndexes(i, 1)
+	p.relink()
+}
+
+// DeleteLine removes the line numbered n while the program is paused,
+// and reports whether there was one. References to the line, such as PC
+// stopped at it, move on to the line after it.
+func (p *Interpreter) DeleteLine(n int64) bool {
+	i, found := p.Locs[n]
+	if !found {
+		return false
+	}
+	lines := make([]ast.Stmt, 0, len(p.Lines)-1)
+	lines = append(lines, p.Lines[:i]...)
+	p.Lines = append(lines, p.Lines[i+1:]...)
+	p.moveIndexes(i, -1)
+	p.relink()
+	return true
+}
+
+// moveIndexes updates the statement indexes the program holds after a
+// line was inserted at index i, when delta is 1, or removed from it, when
+// delta is -1. Indexes of the statement after one, such as the start of
+// a FOR body or a return address, follow the statement before them.
+func (p *Interpreter) moveIndexes(i, delta int) {
+	move := func(x *int, after bool) {
+		if *x > i || *x == i && delta > 0 && !after {
+			*x += delta
+		}
+	}
+	move(&p.PC, false)
+	for j := range p.Fors {
+		move(&p.Fors[j].Block, true)
+	}
+	for j := range p.Whiles {
+		move(&p.Whiles[j].Block, false)
+	}
+	for j := range p.Subs {
+		move(&p.Subs[j].Return, true)
+	}
+	move(&p.onErr.handler, false)
+	move(&p.onErr.pc, false)
+}
@@ -0,0 +1,69 @@
+package interp
+
+import (
+	"fmt"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// FlushMach is implemented by machines that buffer output. Flush is
+// called when the program ends.
+type FlushMach interface {
+	Mach
+	Flush() error
+}
+
+// end stops the program for reason, at an END statement at pos or on
+// running off the end of the program. Pending GOSUBs and SUBs are returned from, so
+// that Vars is left as the main program would see it, and open FOR and
+// WHILE loops are dropped, or reported if StrictEnd is set. Open files
+// are closed, and output is then flushed if the machine buffers it.
+func (p *Interpreter) end(pos scanner.Position, reason HaltReason) error {
+	p.Halt = true
+	p.reason = reason
+
+	var err error
+	if p.StrictEnd {
+		err = p.unclosed(pos)
+	}
+	for len(p.Subs) > 0 {
+		p.ret()
+	}
+	p.Fors = p.Fors[:0]
+	p.Whiles = p.Whiles[:0]
+	p.pending = nil
+	if ferr := p.closeFiles(); ferr != nil && err == nil {
+		err = &ast.Error{Pos: pos, Err: ferr}
+	}
+
+	if m, ok := p.Mach.(FlushMach); ok {
+		if ferr := m.Flush(); ferr != nil && err == nil {
+			err = &ast.Error{Pos: pos, Err: ferr}
+		}
+	}
+	return err
+}
+
+// unclosed returns an error for the innermost FOR or WHILE loop still
+// open, if any, positioned at the statement that opened it.
+func (p *Interpreter) unclosed(pos scanner.Position) error {
+	if n := len(p.Fors); n > 0 {
+		f := p.Fors[n-1]
+		return &ast.Error{Pos: p.linePos(f.Block-1, pos), Err: fmt.Errorf("end: for %v without next", f.Var)}
+	}
+	if n := len(p.Whiles); n > 0 {
+		w := p.Whiles[n-1]
+		return &ast.Error{Pos: p.linePos(w.Block, pos), Err: fmt.Errorf("end: while without wend")}
+	}
+	return nil
+}
+
+// linePos returns the position of the i'th line, or def if there is no
+// such line.
+func (p *Interpreter) linePos(i int, def scanner.Position) scanner.Position {
+	if i < 0 || i >= len(p.Lines) {
+		return def
+	}
+	return p.Lines[i].Pos()
+}
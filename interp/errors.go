@@ -0,0 +1,68 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// Runtime errors are reported as *ast.Error, which carries the source
+// position and the BASIC line number of the failing statement, and wrap
+// one of the errors below when the failure is of a known kind, so that
+// embedders can tell them apart with errors.Is.
+var (
+	// ErrTypeMismatch is wrapped when a value has the wrong kind for
+	// an operator, statement or function.
+	ErrTypeMismatch = errors.New("type mismatch")
+
+	// ErrUndefinedVariable is wrapped when a variable is read before it
+	// is assigned.
+	ErrUndefinedVariable = errors.New("undefined variable")
+
+	// ErrUndefinedFunction is wrapped when a function, sub or procedure
+	// is called that does not exist.
+	ErrUndefinedFunction = errors.New("undefined function")
+
+	// ErrArgumentCount is wrapped when a function or sub is called with
+	// the wrong number of arguments.
+	ErrArgumentCount = errors.New("wrong number of arguments")
+
+	// ErrDivisionByZero is wrapped when a number is divided by zero.
+	ErrDivisionByZero = errors.New("division by zero")
+
+	// ErrBadGoto is wrapped when a GOTO, GOSUB or other jump names a
+	// line or label that does not exist.
+	ErrBadGoto = errors.New("bad jump target")
+
+	// ErrIndex is wrapped when an array is indexed out of range or with
+	// the wrong number of dimensions.
+	ErrIndex = errors.New("index out of range")
+
+	// ErrUnmatchedNext, ErrUnmatchedWend and ErrUnmatchedReturn are
+	// wrapped when NEXT, WEND or RETURN run without a FOR, WHILE or
+	// GOSUB to go back to.
+	ErrUnmatchedNext   = errors.New("unmatched next")
+	ErrUnmatchedWend   = errors.New("unmatched wend")
+	ErrUnmatchedReturn = errors.New("unmatched return")
+)
+
+// kindError is an error of a known kind that keeps its own message.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// errk is like errf, but the error reported also wraps kind.
+func (p *Interpreter) errk(pos scanner.Position, kind error, format string, args ...interface{}) {
+	panic(&ast.Error{Pos: pos, Err: &kindError{kind, fmt.Sprintf(format, args...)}})
+}
@@ -0,0 +1,60 @@
+package interp
+
+import "github.com/qeedquan/go-ubasic/ast"
+
+// EventKind says what an Event reports.
+type EventKind int
+
+const (
+	// EventStarted is sent when a program is loaded and about to run.
+	EventStarted EventKind = iota
+
+	// EventLine is sent before each statement is executed.
+	EventLine
+
+	// EventAssigned is sent after a variable is assigned or removed.
+	EventAssigned
+
+	// EventHalted is sent when the program stops, by ending or failing.
+	EventHalted
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventStarted:
+		return "started"
+	case EventLine:
+		return "line"
+	case EventAssigned:
+		return "assigned"
+	case EventHalted:
+		return "halted"
+	}
+	return "unknown"
+}
+
+// Event describes a change in the state of an interpreter, for hosts
+// that show a program as it runs.
+type Event struct {
+	Kind EventKind
+
+	// Stmt is the statement about to be executed, for EventLine.
+	Stmt ast.Stmt
+
+	// Name and Value are the variable assigned and its new value, or
+	// nil if it was removed, for EventAssigned. Assigning an element of
+	// an array passes the array.
+	Name  string
+	Value Value
+
+	// Err is the error the program failed with, for EventHalted, or nil
+	// if it ended normally, and Reason is why it stopped.
+	Err    error
+	Reason HaltReason
+}
+
+func (p *Interpreter) emit(e Event) {
+	if p.Events != nil {
+		p.Events(e)
+	}
+}
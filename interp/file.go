@@ -0,0 +1,230 @@
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// FileSystem is what OPEN reads and writes files through. Hosts embedding
+// the interpreter can confine programs to a directory with DirFileSystem,
+// supply their own, or set Interpreter.Files to nil to deny file access.
+type FileSystem interface {
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+	Append(name string) (io.WriteCloser, error)
+}
+
+// OSFileSystem is the FileSystem of the operating system, with names
+// relative to the working directory.
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
+func (OSFileSystem) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+func (OSFileSystem) Append(name string) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// DirFileSystem is a FileSystem of the files under a directory. Names
+// are slash separated and relative to it, and may not lead out of it.
+type DirFileSystem string
+
+func (d DirFileSystem) path(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(path.Clean(name))), nil
+}
+
+func (d DirFileSystem) Open(name string) (io.ReadCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return OSFileSystem{}.Open(p)
+}
+
+func (d DirFileSystem) Create(name string) (io.WriteCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return OSFileSystem{}.Create(p)
+}
+
+func (d DirFileSystem) Append(name string) (io.WriteCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return OSFileSystem{}.Append(p)
+}
+
+// maxChannel is the highest channel number files can be opened on.
+const maxChannel = 255
+
+// channel is a file opened by OPEN. Output is not buffered, so that what
+// was written is not lost if the program stops with an error.
+type channel struct {
+	c   io.Closer
+	r   *bufio.Reader
+	w   io.Writer
+	col int
+}
+
+func (c *channel) write(s string) error {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		c.col = len(s) - i - 1
+	} else {
+		c.col += len(s)
+	}
+	_, err := io.WriteString(c.w, s)
+	return err
+}
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "EOF",
+		Syntax: "EOF(n)",
+		Doc:    "whether the file open for input on channel n has no more lines",
+		Params: []Kind{IntKind},
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			c, err := p.channel(int64(args[0].(Int)))
+			if err != nil {
+				return nil, err
+			}
+			if c.r == nil {
+				return nil, fmt.Errorf("#%d is not open for input", args[0])
+			}
+			_, err = c.r.Peek(1)
+			return p.boolean(err == io.EOF), nil
+		},
+	})
+}
+
+func (p *Interpreter) channel(n int64) (*channel, error) {
+	c := p.files[n]
+	if c == nil {
+		return nil, fmt.Errorf("#%d is not open", n)
+	}
+	return c, nil
+}
+
+// fileChannel evaluates e as the number of an open channel.
+func (p *Interpreter) fileChannel(e ast.Expr) *channel {
+	c, err := p.channel(p.int(e))
+	if err != nil {
+		p.errf(ast.ExprPos(e), "%w", err)
+	}
+	return c
+}
+
+func (p *Interpreter) open(s *ast.OpenStmt) {
+	name, ok := p.expr(s.Name).(String)
+	if !ok {
+		p.errf(ast.ExprPos(s.Name), "open: %w: expected file name", ErrTypeMismatch)
+	}
+	n := p.int(s.Channel)
+	if n < 1 || n > maxChannel {
+		p.errf(ast.ExprPos(s.Channel), "open: channel #%d out of range", n)
+	}
+	if p.files[n] != nil {
+		p.errf(ast.ExprPos(s.Channel), "open: #%d is already open", n)
+	}
+	if p.Files == nil {
+		p.errf(s.Open.Pos, "open: file access is not allowed")
+	}
+
+	c := &channel{}
+	var err error
+	switch {
+	case s.Mode.Type == lex.INPUT:
+		var r io.ReadCloser
+		if r, err = p.Files.Open(string(name)); err == nil {
+			c.c, c.r = r, bufio.NewReader(r)
+		}
+	case strings.EqualFold(s.Mode.Text, "output"):
+		var w io.WriteCloser
+		if w, err = p.Files.Create(string(name)); err == nil {
+			c.c, c.w = w, w
+		}
+	default:
+		var w io.WriteCloser
+		if w, err = p.Files.Append(string(name)); err == nil {
+			c.c, c.w = w, w
+		}
+	}
+	if err != nil {
+		p.errf(ast.ExprPos(s.Name), "open: %w", err)
+	}
+	if p.files == nil {
+		p.files = make(map[int64]*channel)
+	}
+	p.files[n] = c
+}
+
+func (p *Interpreter) close(s *ast.CloseStmt) {
+	if len(s.Channels) == 0 {
+		if err := p.closeFiles(); err != nil {
+			p.errf(s.Close.Pos, "close: %w", err)
+		}
+		return
+	}
+	for _, e := range s.Channels {
+		n := p.int(e)
+		c, err := p.channel(n)
+		if err == nil {
+			delete(p.files, n)
+			err = c.c.Close()
+		}
+		if err != nil {
+			p.errf(ast.ExprPos(e), "close: %w", err)
+		}
+	}
+}
+
+// closeFiles closes all open files, returning the first error.
+func (p *Interpreter) closeFiles() error {
+	var chans []int64
+	for n := range p.files {
+		chans = append(chans, n)
+	}
+	sort.Slice(chans, func(i, j int) bool { return chans[i] < chans[j] })
+
+	var err error
+	for _, n := range chans {
+		if cerr := p.files[n].c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(p.files, n)
+	}
+	return err
+}
+
+// fileLine reads the next line from the file open on the channel e for
+// INPUT #.
+func (p *Interpreter) fileLine(e ast.Expr) string {
+	c := p.fileChannel(e)
+	if c.r == nil {
+		p.errf(ast.ExprPos(e), "input: #%d is not open for input", p.int(e))
+	}
+	line, err := c.r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		p.errf(ast.ExprPos(e), "input: #%d: end of file", p.int(e))
+	}
+	if err != nil && err != io.EOF {
+		p.errf(ast.ExprPos(e), "input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
@@ -0,0 +1,162 @@
+package interp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileMach is a machine whose memory is the bytes of a file, so that
+// programs can keep their state across runs, or PEEK around in binary
+// files. Memory past the end of the file reads as zero, and the file
+// grows as it is written there. Wider values are in the byte order Order,
+// little endian if it is nil, as for MemMach. Negative addresses and
+// failures to read or write the file are errors. Output goes to, and
+// input comes from, the underlying Mach.
+type FileMach struct {
+	Mach
+	File interface {
+		io.ReaderAt
+		io.WriterAt
+	}
+	Order binary.ByteOrder
+}
+
+// OpenFileMach returns a FileMach for the file name, which is created if
+// it does not exist, that writes output to mach. The file should be
+// closed with Close when the program is done.
+func OpenFileMach(mach Mach, name string) (*FileMach, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileMach{Mach: mach, File: f}, nil
+}
+
+// Close closes the file if it can be closed.
+func (m *FileMach) Close() error {
+	if c, ok := m.File.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (m *FileMach) order() binary.ByteOrder {
+	if m.Order == nil {
+		return binary.LittleEndian
+	}
+	return m.Order
+}
+
+// read returns the n bytes at addr.
+func (m *FileMach) read(addr int64, n int) ([]byte, error) {
+	if addr < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrAddress, addr)
+	}
+	b := make([]byte, n)
+	if _, err := m.File.ReadAt(b, addr); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+// write writes b at addr.
+func (m *FileMach) write(addr int64, b []byte) error {
+	if addr < 0 {
+		return fmt.Errorf("%w: %d", ErrAddress, addr)
+	}
+	_, err := m.File.WriteAt(b, addr)
+	return err
+}
+
+// mustRead is like read, but panics with the error. The interpreter
+// reports it at the statement reading.
+func (m *FileMach) mustRead(addr int64, n int) []byte {
+	b, err := m.read(addr, n)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// mustWrite is like write, but panics with the error.
+func (m *FileMach) mustWrite(addr int64, b []byte) {
+	if err := m.write(addr, b); err != nil {
+		panic(err)
+	}
+}
+
+func (m *FileMach) Peek(addr int64) int64 {
+	return int64(m.mustRead(addr, 1)[0])
+}
+
+func (m *FileMach) Poke(addr, value int64) {
+	m.mustWrite(addr, []byte{byte(value)})
+}
+
+func (m *FileMach) PeekErr(addr int64) (int64, error) {
+	b, err := m.read(addr, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int64(b[0]), nil
+}
+
+func (m *FileMach) PokeErr(addr, value int64) error {
+	return m.write(addr, []byte{byte(value)})
+}
+
+func (m *FileMach) PeekSized(addr int64, bits int) int64 {
+	v, err := m.PeekSizedErr(addr, bits)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (m *FileMach) PokeSized(addr int64, bits int, value int64) {
+	if err := m.PokeSizedErr(addr, bits, value); err != nil {
+		panic(err)
+	}
+}
+
+func (m *FileMach) PeekSizedErr(addr int64, bits int) (int64, error) {
+	b, err := m.read(addr, bits/8)
+	if err != nil {
+		return 0, err
+	}
+	switch bits {
+	case 16:
+		return int64(m.order().Uint16(b)), nil
+	case 32:
+		return int64(m.order().Uint32(b)), nil
+	}
+	return int64(b[0]), nil
+}
+
+func (m *FileMach) PokeSizedErr(addr int64, bits int, value int64) error {
+	b := make([]byte, bits/8)
+	switch bits {
+	case 16:
+		m.order().PutUint16(b, uint16(value))
+	case 32:
+		m.order().PutUint32(b, uint32(value))
+	default:
+		b[0] = byte(value)
+	}
+	return m.write(addr, b)
+}
+
+// Read, ReadLine and Key pass on the input of the underlying Mach.
+func (m *FileMach) Read(b []byte) (int, error) { return readFrom(m.Mach, b) }
+func (m *FileMach) ReadLine() (string, error)  { return readLineFrom(m.Mach) }
+func (m *FileMach) Key() (rune, bool)          { return keyFrom(m.Mach) }
+
+// Flush flushes the underlying Mach if it buffers output.
+func (m *FileMach) Flush() error {
+	if f, ok := m.Mach.(FlushMach); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package interp
+
+import (
+	"context"
+	"errors"
+)
+
+// HaltReason says why a program stopped running.
+type HaltReason int
+
+const (
+	// NotHalted means the program has not stopped: it is running, or
+	// paused between statements by a host stepping it.
+	NotHalted HaltReason = iota
+
+	// HaltEnd means the program ran an END statement.
+	HaltEnd
+
+	// HaltEndOfProgram means the program ran past its last line.
+	HaltEndOfProgram
+
+	// HaltError means a statement failed with an error that ON ERROR
+	// did not trap.
+	HaltError
+
+	// HaltLimit means the program exceeded a limit such as MaxSteps,
+	// Timeout or MaxDepth.
+	HaltLimit
+
+	// HaltCanceled means the context of the interpreter was cancelled.
+	HaltCanceled
+
+	// HaltBreak means BeforeStatement returned an error, as a debugger
+	// does at a breakpoint.
+	HaltBreak
+)
+
+func (r HaltReason) String() string {
+	switch r {
+	case NotHalted:
+		return "not halted"
+	case HaltEnd:
+		return "end"
+	case HaltEndOfProgram:
+		return "end of program"
+	case HaltError:
+		return "error"
+	case HaltLimit:
+		return "limit exceeded"
+	case HaltCanceled:
+		return "canceled"
+	case HaltBreak:
+		return "break"
+	}
+	return "unknown"
+}
+
+// Halted reports whether the program has stopped, and why. Halt is only
+// set when the program ended; a program stopped for any other reason can
+// be resumed with Continue.
+func (p *Interpreter) Halted() (bool, HaltReason) {
+	return p.reason != NotHalted, p.reason
+}
+
+// haltReason returns why err stopped the program.
+func haltReason(err error) HaltReason {
+	switch {
+	case errors.Is(err, ErrLimit):
+		return HaltLimit
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return HaltCanceled
+	}
+	return HaltError
+}
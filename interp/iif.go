@@ -0,0 +1,44 @@
+package interp
+
+import (
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "IIF",
+		Syntax: "IIF(cond, a, b)",
+		Doc:    "a if cond is true, otherwise b; only the one chosen is evaluated",
+		Params: []Kind{FloatKind, anyKind, anyKind},
+		Result: anyKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			t, err := IsTrue(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if t {
+				return args[1], nil
+			}
+			return args[2], nil
+		},
+	})
+}
+
+// iif evaluates IIF(cond, a, b) without evaluating the branch not taken,
+// so that it can guard against errors such as dividing by zero.
+func (p *Interpreter) iif(e *ast.CallExpr) Value {
+	if len(e.Args) != 3 {
+		p.errk(e.Lparen.Pos, ErrArgumentCount, "IIF: wrong number of arguments, expected IIF(cond, a, b)")
+	}
+	if p.truth(e.Args[0]) {
+		return p.expr(e.Args[1])
+	}
+	return p.expr(e.Args[2])
+}
+
+// isIIF reports whether e is a call of IIF rather than of an array.
+func isIIF(e *ast.CallExpr) bool {
+	return strings.EqualFold(e.Func.Name, "IIF")
+}
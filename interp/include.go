@@ -0,0 +1,158 @@
+package interp
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/lex"
+	"github.com/qeedquan/go-ubasic/parse"
+)
+
+//go:embed std/*.bas
+var stdFiles embed.FS
+
+// Stdlib holds the standard library, BASIC source files included with
+// INCLUDE "std:name" that are built into the interpreter so that programs
+// can use them wherever it runs.
+var Stdlib, _ = fs.Sub(stdFiles, "std")
+
+// libraries maps the prefix of an INCLUDE path to the file system the
+// library is read from.
+var libraries = map[string]fs.FS{
+	"std": Stdlib,
+}
+
+// Libraries returns the paths of the standard libraries in sorted order.
+func Libraries() []string {
+	var paths []string
+	names, _ := fs.Glob(Stdlib, "*.bas")
+	for _, name := range names {
+		paths = append(paths, "std:"+strings.TrimSuffix(name, ".bas"))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// isLibrary reports whether path names a library, as in "std:strings",
+// rather than a file. A single letter before the colon is taken to be a
+// Windows drive.
+func isLibrary(path string) bool {
+	i := strings.IndexByte(path, ':')
+	return i > 1 || i == 1 && len(path) > 2 && path[2] != '\\' && path[2] != '/'
+}
+
+// readLibrary reads the library with the given INCLUDE path.
+func readLibrary(path string) ([]byte, error) {
+	i := strings.IndexByte(path, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("%q is not a library path such as \"std:strings\"", path)
+	}
+	fsys, ok := libraries[path[:i]]
+	if !ok {
+		return nil, fmt.Errorf("unknown library %q", path)
+	}
+	src, err := fs.ReadFile(fsys, path[i+1:]+".bas")
+	if err != nil {
+		return nil, fmt.Errorf("unknown library %q", path)
+	}
+	return src, nil
+}
+
+// includePath returns the path of the library or file named by an INCLUDE
+// statement in the file from. File names are relative to the directory
+// of the file including them.
+func includePath(from, path string) string {
+	if isLibrary(path) || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// cycle returns the chain of files by which from came to include path,
+// or nil if it did not.
+func (c *compiler) cycle(from, path string) []string {
+	var chain []string
+	for f := from; ; f = c.parents[f] {
+		chain = append([]string{f}, chain...)
+		if f == path {
+			return append(chain, path)
+		}
+		if f == filepath.Clean(c.prog.Name) || c.parents[f] == "" {
+			return nil
+		}
+	}
+}
+
+// include adds the lines of the libraries and files named by INCLUDE
+// statements to the end of the program, numbered after its last line.
+// Their lines need not be numbered, and each is added once however many
+// times it is included, but a file may not include itself, directly or
+// through others. Files are read through the compiler's FileSystem when
+// the program is compiled and lexed as the program is, and their lines
+// keep their own positions so that errors point into them.
+func (c *compiler) include() error {
+	for len(c.pending) > 0 {
+		s := c.pending[0]
+		c.pending = c.pending[1:]
+		from := s.Path.Pos.Filename
+		if !isLibrary(from) {
+			from = filepath.Clean(from)
+		}
+		path := includePath(from, s.Path.Value)
+		if chain := c.cycle(from, path); chain != nil {
+			return &parse.Error{Pos: s.Path.Pos, Err: fmt.Errorf("include: cycle %s", strings.Join(chain, " -> "))}
+		}
+		if c.included[path] {
+			continue
+		}
+		c.included[path] = true
+		c.parents[path] = from
+
+		var src []byte
+		var err error
+		switch {
+		case isLibrary(path):
+			src, err = readLibrary(path)
+		case isLibrary(from):
+			err = fmt.Errorf("library %s cannot include the file %q", from, path)
+		case c.files == nil:
+			err = fmt.Errorf("file access is not allowed")
+		default:
+			src, err = readProgram(c.files, path)
+		}
+		if err != nil {
+			return &parse.Error{Pos: s.Path.Pos, Err: fmt.Errorf("include: %w", err)}
+		}
+		var last int64
+		for _, l := range c.prog.Lines {
+			if n := l.Line(); n > last {
+				last = n
+			}
+		}
+
+		var lexer lex.Tokenizer
+		lexer.Init(c.conf, path, src)
+		parser := parse.NewParser(&lexer)
+		parser.AutoNumber = true
+		parser.TrueValue = c.prog.Options.TrueValue
+		parser.Continue(last)
+		if err := c.parse(parser); err != nil {
+			return err
+		}
+		c.prog.Includes = append(c.prog.Includes, path)
+	}
+	return nil
+}
+
+// include checks that the library or file was added when the program
+// was compiled, as it cannot be when running a stream or in the REPL.
+func (p *Interpreter) include(s *ast.IncludeStmt) {
+	if !p.included[includePath(s.Path.Pos.Filename, s.Path.Value)] {
+		p.errf(s.Path.Pos, "include: %s can only be included in a compiled program", s.Path.Value)
+	}
+}
@@ -0,0 +1,253 @@
+package interp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// inputQueue holds lines supplied by the host through ProvideInput until
+// an INPUT statement consumes them.
+type inputQueue struct {
+	mu    sync.Mutex
+	lines []string
+	ready chan struct{}
+}
+
+func newInputQueue() *inputQueue {
+	return &inputQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (q *inputQueue) push(line string) {
+	q.mu.Lock()
+	q.lines = append(q.lines, line)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *inputQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.lines) == 0 {
+		return "", false
+	}
+	line := q.lines[0]
+	q.lines = q.lines[1:]
+	return line, true
+}
+
+// ProvideInput queues a line to be consumed by the next INPUT statement.
+// It is safe to call from a goroutine other than the one running the
+// interpreter.
+func (p *Interpreter) ProvideInput(line string) {
+	p.input.push(line)
+}
+
+func (p *Interpreter) ctx() context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
+}
+
+// readLine returns the next line of program input after applying the
+// echo and transform settings.
+func (p *Interpreter) readLine(pos scanner.Position) string {
+	line := p.nextLine(pos)
+	p.col = 0
+	p.rows = 0
+	if p.EchoInput {
+		io.WriteString(p.Mach, line+"\n")
+	}
+	if p.InputTransform != nil {
+		line = p.InputTransform(line)
+	}
+	return line
+}
+
+// nextLine returns the next raw line of input, failing at pos if there
+// is none.
+func (p *Interpreter) nextLine(pos scanner.Position) string {
+	e, err := p.effect(Effect{Kind: "input"}, func(e *Effect) (err error) {
+		e.Text, err = p.rawLine()
+		return err
+	})
+	if err != nil {
+		p.errf(pos, "input: %w", err)
+	}
+	return e.Text
+}
+
+// LineMach is implemented by machines that read input a line at a time,
+// such as a terminal with line editing or a device with a keypad and
+// enter key. ReadLine returns the next line without its terminator, or
+// io.EOF if there is no more input. It is preferred to reading the Mach
+// as an io.Reader.
+type LineMach interface {
+	Mach
+	ReadLine() (string, error)
+}
+
+// readFrom reads from m for machines that wrap it, returning io.EOF if m
+// has no input.
+func readFrom(m Mach, b []byte) (int, error) {
+	if r, ok := m.(io.Reader); ok {
+		return r.Read(b)
+	}
+	return 0, io.EOF
+}
+
+// readLineFrom reads a line from m for machines that wrap it, with
+// ReadLine if it is a LineMach, and otherwise a byte at a time so that
+// no input past the line is taken from it.
+func readLineFrom(m Mach) (string, error) {
+	if l, ok := m.(LineMach); ok {
+		return l.ReadLine()
+	}
+	var line []byte
+	var b [1]byte
+	for {
+		n, err := readFrom(m, b[:])
+		if n > 0 && b[0] == '\n' {
+			break
+		}
+		line = append(line, b[:n]...)
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
+
+// rawLine returns the next raw line of input. Lines queued by the host
+// take priority; if none are pending and the Mach is a LineMach or an
+// io.Reader, a line is read from it. Otherwise rawLine blocks until the
+// host provides a line or the interpreter context is cancelled.
+func (p *Interpreter) rawLine() (string, error) {
+	for {
+		if line, ok := p.input.pop(); ok {
+			return line, nil
+		}
+
+		if m, ok := p.Mach.(LineMach); ok && p.rd == nil {
+			return m.ReadLine()
+		}
+		if p.rd == nil {
+			if r, ok := p.Mach.(io.Reader); ok {
+				p.rd = bufio.NewReader(r)
+			}
+		}
+		if p.rd != nil {
+			line, err := p.rd.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				return "", err
+			}
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+
+		ctx := p.ctx()
+		select {
+		case <-p.input.ready:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// input_ reads one field for each variable from a comma separated line.
+// Variables ending in $ take the field as a string, which may be quoted
+// to hold commas, others must be given a number; if any field is malformed or the count is wrong, the line is
+// asked for again.
+func (p *Interpreter) input_(s *ast.InputStmt) {
+	values := make([]Value, len(s.Vars))
+	if s.Channel != nil {
+		if !p.inputFields(p.fileLine(s.Channel), s.Vars, values) {
+			p.errf(s.Input.Pos, "input: #%d: wrong number or kind of fields", p.int(s.Channel))
+		}
+		p.setInput(s.Vars, values)
+		return
+	}
+
+	prompt := p.InputPrompt
+	if s.Prompt != nil {
+		prompt = s.Prompt.Value
+		if s.Sep.Type == lex.SEMICOLON {
+			prompt += p.InputPrompt
+		}
+	}
+
+	for {
+		p.write(prompt)
+		if p.inputFields(p.readLine(s.Label.Pos), s.Vars, values) {
+			break
+		}
+		p.write("?Redo from start\n")
+	}
+	p.setInput(s.Vars, values)
+}
+
+func (p *Interpreter) setInput(vars []ast.Variable, values []Value) {
+	for i, v := range vars {
+		p.setVar(v.Name, values[i])
+	}
+}
+
+func (p *Interpreter) inputFields(line string, vars []ast.Variable, values []Value) bool {
+	fields := splitFields(line)
+	if len(fields) != len(vars) {
+		return false
+	}
+	for i, f := range fields {
+		f = strings.TrimSpace(f)
+		if strings.HasSuffix(vars[i].Name, "$") {
+			if len(f) >= 2 && f[0] == '"' && f[len(f)-1] == '"' {
+				f = f[1 : len(f)-1]
+			}
+			values[i] = String(f)
+		} else if n, err := strconv.ParseInt(f, 10, 64); err == nil {
+			values[i] = p.wrap(Int(n))
+		} else if x, err := strconv.ParseFloat(f, 64); err == nil && !strings.ContainsAny(f, "_xX") {
+			// Numbers are typed in decimal, without the hexadecimal
+			// and digit separators of Go that ParseFloat accepts.
+			values[i] = Float(x)
+		} else {
+			return false
+		}
+	}
+	return true
+}
+
+// splitFields splits line at the commas outside double quotes.
+func splitFields(line string) []string {
+	var fields []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				fields = append(fields, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, line[start:])
+}
@@ -0,0 +1,32 @@
+package interp_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/qeedquan/go-ubasic/interp"
+	"github.com/qeedquan/go-ubasic/interp/interptest"
+)
+
+// TestInputQuoted runs testdata/inputcsv.bas, which writes fields with
+// commas in quotes to a file and reads them back with INPUT #.
+func TestInputQuoted(t *testing.T) {
+	const name = "../testdata/inputcsv.bas"
+	src, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := interp.Compile(name, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &interptest.Mach{Values: make(map[int64]int64)}
+	p := interp.NewInterpreter(m, interp.WithFiles(interp.DirFileSystem(t.TempDir())))
+	if err := p.Run(prog); err != nil {
+		t.Fatal(err)
+	}
+	want := "Smith, John|42\nDoe|7\na, b, c|d\n"
+	if got := m.Out.String(); got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+}
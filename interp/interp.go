@@ -2,16 +2,46 @@ package interp
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/scanner"
+	"time"
 
 	"github.com/qeedquan/go-ubasic/ast"
 	"github.com/qeedquan/go-ubasic/lex"
 	"github.com/qeedquan/go-ubasic/parse"
 )
 
+// ErrLimit is wrapped by the errors reported when a program is stopped
+// for exceeding an execution limit.
+var ErrLimit = errors.New("limit exceeded")
+
+// ErrStepLimit is wrapped by the error reported when a program executes
+// more statements than MaxSteps allows. It wraps ErrLimit.
+var ErrStepLimit = fmt.Errorf("step %w", ErrLimit)
+
+// ErrTimeLimit is wrapped by the error reported when a program runs for
+// longer than Timeout allows. It wraps ErrLimit.
+var ErrTimeLimit = fmt.Errorf("time %w", ErrLimit)
+
+// Tracer is implemented by tools that follow the execution of programs,
+// such as loggers, coverage tools and profilers. Step calls Before just
+// before executing a statement and After just after, with the error the
+// statement failed with, if any, before an ON ERROR handler sees it. The
+// statements of a FUNCTION are traced too, between the Before and After
+// of the statement calling it.
+type Tracer interface {
+	Before(s ast.Stmt)
+	After(s ast.Stmt, err error)
+}
+
 type Mach interface {
 	io.Writer
 	Peek(addr int64) int64
@@ -22,6 +52,7 @@ type Stdio struct {
 	Values map[int64]int64
 }
 
+func (Stdio) Read(b []byte) (int, error)  { return os.Stdin.Read(b) }
 func (Stdio) Write(b []byte) (int, error) { return os.Stdout.Write(b) }
 func (s *Stdio) Peek(addr int64) int64    { return s.Values[addr] }
 func (s *Stdio) Poke(addr, value int64)   { s.Values[addr] = value }
@@ -35,25 +66,211 @@ func NewStdio() *Stdio {
 type ForStack struct {
 	Block int
 	Var   string
-	To    int64
+	To    Value
+}
+
+// Frame is pushed by GOSUB or a call and popped by RETURN, END SUB or END
+// FUNCTION. Saved holds the values that parameters and variables declared
+// LOCAL in the subroutine had in the caller; a nil value means the
+// variable did not exist and is removed on return. Sub is the name of the
+// SUB or FUNCTION called, or empty for a GOSUB, and Func is set for a
+// FUNCTION.
+type Frame struct {
+	Return int
+	Saved  map[string]Value
+	Sub    string
+	Func   bool
+}
+
+type WhileStack struct {
+	Block int
 }
 
+// Interpreter runs a program. It is not safe for concurrent use, but
+// interpreters share no mutable state with each other, so each can run
+// in a goroutine of its own; those sharing a Mach should share it
+// through a SyncMach.
 type Interpreter struct {
 	Mach Mach
+
+	// Context, if not nil, stops the program before the next statement
+	// once it is cancelled, and interrupts INPUT and host calls waiting
+	// on it. RunContext sets it.
+	Context context.Context
+
+	// Halt is set once the program has ended. Halted also says why the
+	// program stopped, for any reason.
 	Halt bool
 	PC   int
 
-	Vars  map[string]int64
-	Subs  []int
-	Fors  []ForStack
-	Locs  map[int64]int
-	Lines []ast.Stmt
+	// InputPrompt is written before INPUT reads a line. If EchoInput is
+	// set, lines read are written back to the Mach so that transcripts of
+	// non-interactive sessions look like the interactive ones.
+	// InputTransform, if non-nil, is applied to each line after it is
+	// echoed and before the program sees it.
+	InputPrompt    string
+	EchoInput      bool
+	InputTransform func(line string) string
+
+	// MaxDepth limits how deeply GOSUBs may nest; zero means no limit.
+	// A GOSUB directly followed by RETURN is a tail call and reuses the
+	// current frame, so it does not count against the limit.
+	MaxDepth int
+
+	// MaxSteps limits the number of statements executed after the program
+	// was loaded, so that hosts can run programs they do not trust without
+	// an endless loop occupying them forever; zero means no limit. The
+	// maxsteps option of a program can only lower it. Steps counts the
+	// statements executed, including those of FUNCTIONs and of programs
+	// restarted by RUN.
+	MaxSteps int64
+	Steps    int64
+
+	// Timeout limits the time each Run or Continue may take; zero means
+	// no limit. Unlike MaxSteps, it also stops programs blocked in a
+	// statement, such as INPUT or a host call, that respects Context.
+	Timeout time.Duration
+
+	// ZoneWidth is the width of the print zones a comma in PRINT moves
+	// to. If it is zero, a comma prints a single space.
+	ZoneWidth int
+
+	// PrintNewline makes PRINT end the line unless the statement ends
+	// with a semicolon or comma. Programs written for the original
+	// interpreter, where PRINT never ends the line, need it unset.
+	PrintNewline bool
+
+	// Paginate makes output pause with a More prompt after every
+	// PageLength lines until a key is pressed, or a line entered if the
+	// Mach has no keyboard, as on the terminals of old. Hosts should only
+	// set it when the output is a screen someone is reading. The page
+	// length comes from the dialect, unless the program sets it with the
+	// pagelength option.
+	Paginate   bool
+	PageLength int
+
+	// IntWidth is the number of bits integers wrap at, as on the 8, 16
+	// and 32 bit machines old programs were written for. Numbers in the
+	// program, including folded constants, numbers read by INPUT, the
+	// results of arithmetic and functions, and the values of PEEK and
+	// POKE are truncated to it and sign extended. Zero, or 64, uses the
+	// full width of int64.
+	IntWidth int
+
+	// TrueValue is the value of true comparisons and logical operations,
+	// and of TRUE. Zero means 1; classic BASICs use -1, all bits set, so
+	// that bitwise operators combine conditions as logical ones do.
+	// Comparisons in constants are folded when the program is compiled,
+	// with the truevalue option.
+	TrueValue int64
+
+	// Files is the file system OPEN and CHAIN use, and the one the files
+	// included by chained programs are read from. If it is nil, as it is
+	// unless the host sets it, programs cannot open files.
+	Files FileSystem
+
+	// Allow holds the policies whose builtins programs may call.
+	Allow map[string]bool
+
+	// Clock is the source of time for programs.
+	Clock Clock
+
+	// Rand is the source of the numbers RND returns. If it is nil, the
+	// default source of math/rand is used.
+	Rand *rand.Rand
+
+	// Trace writes the line number of each statement executed, as in
+	// [10][20], before executing it. TRON and TROFF set it.
+	Trace bool
+
+	// StrictEnd makes it an error for the program to end, by END or by
+	// running off its last line, while a FOR or WHILE loop is open.
+	StrictEnd bool
+
+	// StrictVars makes reading a variable that was never assigned an
+	// error, in expressions and by NEXT alike. It is set by default; if
+	// it is clear, such a variable reads as 0, or "" if its name ends in
+	// $, as in classic BASICs.
+	StrictVars bool
+
+	// BeforeStatement, if non-nil, is called by Step before executing a
+	// statement. Step waits for the delay it returns, so that hosts can
+	// pace or throttle programs. If it returns an error, the statement is
+	// not executed and Step fails with the error; the program is left at
+	// the statement, so stepping again retries it. It is also called for
+	// the statements of FUNCTIONs, and if it fails for one of those, the
+	// program is left at the statement that called the FUNCTION.
+	BeforeStatement func(s ast.Stmt) (time.Duration, error)
+
+	// Tracer, if non-nil, is told of each statement executed.
+	Tracer Tracer
+
+	// Coverage, if non-nil, records the lines executed.
+	Coverage *Coverage
+
+	// VarChanged, if non-nil, is called after a variable is assigned,
+	// by the program or SetVar, with its new value, or with nil when it
+	// is removed, as when a LOCAL goes out of scope. Assigning an element
+	// of an array passes the array. It is not called when RUN, CLEAR or
+	// Reset clears all variables.
+	VarChanged func(name string, v Value)
+
+	// Events, if non-nil, is called with each event in the life of the
+	// program: its start, every statement, every assignment and its halt.
+	// It is called on the goroutine running the program; hosts that want
+	// the events on a channel can send them from it.
+	Events func(e Event)
+
+	Vars   map[string]Value
+	Subs   []Frame
+	Fors   []ForStack
+	Whiles []WhileStack
+	Locs   map[int64]int
+	Names  map[string]int
+	Procs  map[string]int
+	Lines  []ast.Stmt
+
+	col      int
+	rows     int
+	included map[string]bool
+	files    map[int64]*channel
+	out      *channel
+	bindings map[string]*Binding
+	routines map[int64]HostFunc
+	pending  []*asyncCall
+	ext      map[interface{}]interface{}
+	start    time.Time
+	input    *inputQueue
+	rd       *bufio.Reader
+	stream   *parse.Parser
+	result   Value
+	onErr    errTrap
+	reason   HaltReason
+	journal  *Journal
+
+	// progSteps is the maxsteps option of the program loaded.
+	progSteps int64
 }
 
-func NewInterpreter(mach Mach) *Interpreter {
+// NewInterpreter returns an interpreter for programs running on mach,
+// with the default settings changed by opts.
+func NewInterpreter(mach Mach, opts ...Option) *Interpreter {
 	p := &Interpreter{
-		Mach: mach,
-		Locs: make(map[int64]int),
+		Mach:         mach,
+		InputPrompt:  "? ",
+		MaxDepth:     10000,
+		ZoneWidth:    14,
+		PageLength:   defaultDialect.PageLength,
+		PrintNewline: true,
+		StrictVars:   true,
+		Clock:        systemClock{},
+		Locs:         make(map[int64]int),
+		Names:        make(map[string]int),
+		Procs:        make(map[string]int),
+		input:        newInputQueue(),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 	p.Reset()
 	return p
@@ -61,33 +278,217 @@ func NewInterpreter(mach Mach) *Interpreter {
 
 func (p *Interpreter) Reset() {
 	p.Halt = false
+	p.reason = NotHalted
 	p.PC = 0
-	p.Vars = make(map[string]int64)
+	p.Vars = make(map[string]Value)
 	p.Subs = p.Subs[:0]
 	p.Fors = p.Fors[:0]
+	p.Whiles = p.Whiles[:0]
+	p.pending = nil
+	p.onErr = errTrap{}
+	p.start = p.now()
+	p.closeFiles()
 }
 
-func (p *Interpreter) errf(format string, args ...interface{}) {
-	panic(fmt.Errorf(format, args...))
+func (p *Interpreter) errf(pos scanner.Position, format string, args ...interface{}) {
+	panic(&ast.Error{Pos: pos, Err: fmt.Errorf(format, args...)})
 }
 
+// Step executes the next statement of the program. If the statement
+// fails and ON ERROR does not trap the error, the frames the statement
+// pushed are unwound and the program is left at it, so that a host can
+// report the error and then retry the statement with Continue or pass
+// over it with SkipLine.
 func (p *Interpreter) Step() error {
+	halted := p.Halt
+	if !halted {
+		p.reason = NotHalted
+	}
+	err := p.step()
+	if err != nil && !p.Halt && p.reason == NotHalted {
+		p.reason = haltReason(err)
+	}
+	if !halted && (p.Halt || err != nil) {
+		p.emit(Event{Kind: EventHalted, Err: err, Reason: p.reason})
+	}
+	return err
+}
+
+func (p *Interpreter) step() error {
 	if p.PC >= len(p.Lines) {
-		p.Halt = true
+		more, err := p.more()
+		if err != nil {
+			return err
+		}
+		if !more {
+			return p.end(p.linePos(len(p.Lines)-1, scanner.Position{}), HaltEndOfProgram)
+		}
 	}
 	if p.Halt {
 		return nil
 	}
 
 	s := p.Lines[p.PC]
+	if err := p.beginStep(s); err != nil {
+		return err
+	}
+	pc, depth := p.PC, len(p.Subs)
 	p.PC++
-	return p.Eval(s)
+	err := p.Eval(s)
+	var aerr *ast.Error
+	if errors.As(err, &aerr) && aerr.Line == 0 {
+		aerr.Line = s.Line()
+	}
+	if p.Tracer != nil {
+		p.Tracer.After(s, err)
+	}
+	if err != nil {
+		if err = p.trap(err, pc, depth); err != nil && !p.Halt {
+			p.unwind(pc, depth)
+		}
+		return err
+	}
+	return nil
+}
+
+// unwind returns the program to the statement at index pc, which failed,
+// leaving the frames below depth.
+func (p *Interpreter) unwind(pc, depth int) {
+	for len(p.Subs) > depth {
+		p.ret()
+	}
+	p.result = nil
+	p.PC = pc
+}
+
+// Continue runs the program from where it stopped until it halts or
+// fails, as Run does after loading it. After an error, it starts by
+// executing the statement that failed again.
+func (p *Interpreter) Continue() error {
+	if p.Timeout <= 0 {
+		return p.run()
+	}
+
+	parent := p.Context
+	ctx, cancel := context.WithTimeout(p.ctx(), p.Timeout)
+	defer cancel()
+	p.Context = ctx
+	err := p.run()
+	p.Context = parent
+	if err != nil && ctx.Err() == context.DeadlineExceeded && p.ctx().Err() == nil {
+		err = p.timedOut(err)
+		p.reason = HaltLimit
+	}
+	return err
+}
+
+// timedOut returns the error for a program stopped with err by Timeout,
+// at the position err has if any.
+func (p *Interpreter) timedOut(err error) error {
+	limit := fmt.Errorf("%w (%v)", ErrTimeLimit, p.Timeout)
+	var aerr *ast.Error
+	if errors.As(err, &aerr) {
+		return &ast.Error{Pos: aerr.Pos, Err: limit, Line: aerr.Line}
+	}
+	return limit
+}
+
+// run steps the program until it halts or fails.
+func (p *Interpreter) run() error {
+	for !p.Halt {
+		err := p.Step()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunFor runs the program from where it stopped for at most n statements,
+// or until it halts or fails, and returns the number executed. Hosts
+// with a main loop of their own, such as games, can call it once a frame
+// to share the thread with the program; all state is kept between calls.
+func (p *Interpreter) RunFor(n int) (int, error) {
+	i := 0
+	for ; i < n && !p.Halt; i++ {
+		err := p.Step()
+		if err != nil {
+			return i, err
+		}
+	}
+	return i, nil
+}
+
+// SkipLine moves the program past the statement it is stopped at, such
+// as one that failed, so that Continue resumes with the next.
+func (p *Interpreter) SkipLine() {
+	if !p.Halt && p.PC < len(p.Lines) {
+		p.PC++
+	}
+}
+
+// beginStep prepares to execute s as the next step of the program, be it
+// a statement run by Step or one of a FUNCTION called from it. It returns
+// an error if s may not be executed, because the context is done, the
+// step limit is reached or BeforeStatement failed. Otherwise it counts
+// the step and tells TRON, coverage, Events and the Tracer of it.
+func (p *Interpreter) beginStep(s ast.Stmt) error {
+	if err := p.ctx().Err(); err != nil {
+		return &ast.Error{Pos: s.Pos(), Err: err, Line: s.Line()}
+	}
+	if max := p.maxSteps(); max > 0 && p.Steps >= max {
+		return &ast.Error{Pos: s.Pos(), Err: fmt.Errorf("%w (%d)", ErrStepLimit, max), Line: s.Line()}
+	}
+	if p.BeforeStatement != nil {
+		if err := p.before(s); err != nil {
+			return err
+		}
+	}
+	p.Steps++
+	p.trace(s)
+	p.cover(s)
+	p.emit(Event{Kind: EventLine, Stmt: s})
+	if p.Tracer != nil {
+		p.Tracer.Before(s)
+	}
+	return nil
+}
+
+// trace writes the line number of s if tracing is on.
+func (p *Interpreter) trace(s ast.Stmt) {
+	if p.Trace {
+		p.write(fmt.Sprintf("[%d]", s.Line()))
+	}
+}
+
+// before runs the BeforeStatement hook for s and waits out the delay it
+// asks for, unless the context is cancelled first.
+func (p *Interpreter) before(s ast.Stmt) error {
+	d, err := p.BeforeStatement(s)
+	if err != nil {
+		p.reason = HaltBreak
+		return &ast.Error{Pos: s.Pos(), Err: err, Line: s.Line()}
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-p.ctx().Done():
+		return &ast.Error{Pos: s.Pos(), Err: p.ctx().Err(), Line: s.Line()}
+	}
 }
 
 func (p *Interpreter) Eval(s ast.Stmt) (err error) {
 	defer func() {
 		if e := recover(); e != nil {
-			err = e.(error)
+			if _, ok := e.(halted); !ok {
+				err = positioned(s, e)
+			}
 		}
 	}()
 
@@ -101,202 +502,1027 @@ func (p *Interpreter) stmt(s ast.Stmt) {
 		p.for_(s)
 	case *ast.NextStmt:
 		p.next(s)
+	case *ast.WhileStmt:
+		p.while_(s)
+	case *ast.WendStmt:
+		p.wend(s)
 	case *ast.IfStmt:
 		p.if_(s)
 	case *ast.GotoStmt:
 		p.goto_(s)
 	case *ast.GosubStmt:
 		p.gosub(s)
+	case *ast.RunStmt:
+		p.restart(s)
+	case *ast.OnErrorStmt:
+		p.onError(s)
+	case *ast.ResumeStmt:
+		p.resume(s)
 	case *ast.ReturnStmt:
 		p.return_(s)
+	case *ast.LocalStmt:
+		p.local(s)
+	case *ast.GetStmt:
+		p.get(s)
+	case *ast.CallStmt:
+		p.callStmt(s)
+	case *ast.SubStmt:
+		p.skipProc(s, s.Name)
+	case *ast.EndSubStmt:
+		p.endSub(s)
+	case *ast.FunctionStmt:
+		p.skipProc(s, s.Name)
+	case *ast.EndFunctionStmt:
+		p.endFunction(s)
+	case *ast.TraceStmt:
+		p.Trace = s.Trace.Type == lex.TRON
+	case *ast.ClsStmt:
+		p.cls(s)
+	case *ast.DimStmt:
+		p.dim(s)
+	case *ast.ConstStmt:
+	case *ast.RemStmt:
+	case *ast.IncludeStmt:
+		p.include(s)
+	case *ast.OpenStmt:
+		p.open(s)
+	case *ast.CloseStmt:
+		p.close(s)
+	case *ast.ChainStmt:
+		p.chain(s)
+	case *ast.PopStmt:
+		p.pop(s)
+	case *ast.ClearStmt:
+		p.clear(s)
+	case *ast.SysStmt:
+		p.sys(s)
+	case *ast.LabelStmt:
+	case *ast.PlotStmt:
+		p.plot(s)
+	case *ast.LocateStmt:
+		p.locate_(s)
+	case *ast.ColorStmt:
+		p.color(s)
+	case *ast.WaitStmt:
+		p.wait(s)
 	case *ast.LetStmt:
 		p.assign(s)
 	case *ast.EndStmt:
-		p.Halt = true
+		if err := p.end(s.End.Pos, HaltEnd); err != nil {
+			panic(err)
+		}
 	case *ast.PeekStmt:
-		p.Vars[s.Var.Name] = p.Mach.Peek(p.expr(s.Addr))
+		v, err := p.peek(p.int(s.Addr))
+		if err != nil {
+			p.errf(ast.ExprPos(s.Addr), "peek: %w", err)
+		}
+		p.setVar(s.Var.Name, v)
 	case *ast.PokeStmt:
-		p.Mach.Poke(p.expr(s.Addr), p.expr(s.Value))
+		p.poke(s)
 	case *ast.PrintStmt:
 		p.print(s)
+	case *ast.InputStmt:
+		p.input_(s)
 	}
 
 	return
 }
 
 func (p *Interpreter) for_(s *ast.ForStmt) {
-	p.Vars[s.Var.Name] = p.expr(s.Start)
+	p.setVar(s.Var.Name, p.number(s.Start))
 	p.Fors = append(p.Fors, ForStack{
 		Block: p.PC,
 		Var:   s.Var.Name,
-		To:    p.expr(s.End),
+		To:    p.number(s.End),
 	})
 }
 
+// next ends an iteration of the innermost loop, or of the loop over the
+// variable named, abandoning any loops inside it as other BASICs do when
+// a program jumps out of them.
 func (p *Interpreter) next(s *ast.NextStmt) {
-	if n := len(p.Fors); n > 0 {
-		f := &p.Fors[n-1]
-		if f.Var == s.Var.Name {
-			p.Vars[s.Var.Name]++
+	n := len(p.Fors)
+	if s.Var.Name != "" {
+		for n > 0 && p.Fors[n-1].Var != s.Var.Name {
+			n--
 		}
+	}
+	if n == 0 {
+		p.errk(s.Label.Pos, ErrUnmatchedNext, "non-matching next")
+	}
+	p.Fors = p.Fors[:n]
 
-		if p.Vars[s.Var.Name] <= f.To {
-			p.PC = f.Block
-		} else {
-			p.Fors = p.Fors[:n-1]
-		}
+	f := &p.Fors[n-1]
+	v, ok := p.Vars[f.Var]
+	if !ok {
+		v = p.unassigned(s.Next.Pos, f.Var)
+	}
+	v = p.binary(s.Next.Pos, lex.PLUS, v, Int(1))
+	p.setVar(f.Var, v)
+
+	if p.cond(s.Next.Pos, p.binary(s.Next.Pos, lex.LEQ, v, f.To)) {
+		p.PC = f.Block
 	} else {
-		p.errf("%v: non-matching next", s.Label)
+		p.Fors = p.Fors[:n-1]
+	}
+}
+
+func (p *Interpreter) while_(s *ast.WhileStmt) {
+	if p.truth(s.Cond) {
+		p.Whiles = append(p.Whiles, WhileStack{
+			Block: p.PC - 1,
+		})
+		return
+	}
+
+	depth := 0
+	for i := p.PC; ; i++ {
+		if i >= len(p.Lines) && !p.mustMore() {
+			break
+		}
+		switch p.Lines[i].(type) {
+		case *ast.WhileStmt:
+			depth++
+		case *ast.WendStmt:
+			if depth == 0 {
+				p.PC = i + 1
+				return
+			}
+			depth--
+		}
+	}
+	p.errf(s.Label.Pos, "while without matching wend")
+}
+
+func (p *Interpreter) wend(s *ast.WendStmt) {
+	n := len(p.Whiles)
+	if n == 0 {
+		p.errk(s.Label.Pos, ErrUnmatchedWend, "non-matching wend")
 	}
+	p.PC = p.Whiles[n-1].Block
+	p.Whiles = p.Whiles[:n-1]
 }
 
 func (p *Interpreter) if_(s *ast.IfStmt) {
-	if p.expr(s.Cond) != 0 {
+	if p.truth(s.Cond) {
 		p.stmt(s.Body)
-	} else if s.Else != nil {
+		return
+	}
+	for _, elseif := range s.ElseIf {
+		if p.truth(elseif.Cond) {
+			p.stmt(elseif.Body)
+			return
+		}
+	}
+	if s.Else != nil {
 		p.stmt(s.Else.Body)
 	}
 }
 
 func (p *Interpreter) goto_(s *ast.GotoStmt) {
-	loc, found := p.Locs[s.Location.Value]
-	if !found {
-		p.errf("%v: goto: location %d does not exist", s.Label, s.Location.Value)
+	if s.Expr != nil {
+		p.PC = p.computed("goto", s.Expr)
+		return
 	}
-	p.PC = loc
+	p.PC = p.target("goto", s.Label.Pos, s.Location, s.Target)
+}
+
+// computed returns the index of the line whose number e evaluates to. A
+// string is parsed as a line number, so that programs can jump to a
+// number the user typed.
+func (p *Interpreter) computed(kw string, e ast.Expr) int {
+	pos := ast.ExprPos(e)
+	var line int64
+	switch v := p.expr(e).(type) {
+	case String:
+		n, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+		if err != nil {
+			p.errk(pos, ErrBadGoto, "%s: %q is not a line number", kw, string(v))
+		}
+		line = n
+	default:
+		n, err := AsInt(v)
+		if err != nil {
+			p.errf(pos, "%s: %w", kw, err)
+		}
+		line = n
+	}
+	return p.target(kw, pos, ast.Number{Pos: pos, Value: line}, ast.Variable{})
+}
+
+// restart runs the program again from the start, or from the line given,
+// with all variables cleared and no loops or subroutines active. Like
+// CLEAR, it cannot be used inside a SUB or FUNCTION.
+func (p *Interpreter) restart(s *ast.RunStmt) {
+	if sub := p.inside(); sub != "" {
+		p.errf(s.Run.Pos, "run: not allowed inside %s", sub)
+	}
+	pc := 0
+	if s.Location != nil || s.Target.Name != "" {
+		var line ast.Number
+		if s.Location != nil {
+			line = *s.Location
+		}
+		pc = p.target("run", s.Label.Pos, line, s.Target)
+	}
+	p.Reset()
+	p.PC = pc
 }
 
 func (p *Interpreter) gosub(s *ast.GosubStmt) {
-	p.Subs = append(p.Subs, p.PC)
-	loc, found := p.Locs[s.Location.Value]
-	if !found {
-		p.errf("%v: gosub: location %d does not exist", s.Label, s.Location.Value)
+	var loc int
+	if s.Expr != nil {
+		loc = p.computed("gosub", s.Expr)
+	} else {
+		loc = p.target("gosub", s.Label.Pos, s.Location, s.Target)
+	}
+	if !p.tailCall() {
+		if p.MaxDepth > 0 && len(p.Subs) >= p.MaxDepth {
+			p.errf(s.Label.Pos, "gosub: depth %w (%d)", ErrLimit, p.MaxDepth)
+		}
+		p.Subs = append(p.Subs, Frame{Return: p.PC})
 	}
 	p.PC = loc
 }
 
+// tailCall reports whether the GOSUB being executed is immediately
+// followed by a RETURN. Returning from the callee would then only return
+// again, so the callee can return straight to our caller using the
+// current frame. Locals of the current frame stay saved in it and are
+// restored by the callee's RETURN, just as ours would have been.
+func (p *Interpreter) tailCall() bool {
+	if len(p.Subs) == 0 || p.PC >= len(p.Lines) {
+		return false
+	}
+	r, ok := p.Lines[p.PC].(*ast.ReturnStmt)
+	return ok && r.Value == nil
+}
+
 func (p *Interpreter) return_(s *ast.ReturnStmt) {
 	if len(p.Subs) == 0 {
-		p.errf("%v: non-matching return", s.Label)
+		p.errk(s.Label.Pos, ErrUnmatchedReturn, "non-matching return")
+	}
+	f := p.Subs[len(p.Subs)-1]
+	if f.Func {
+		var v Value
+		if s.Value != nil {
+			v = p.expr(s.Value)
+		}
+		p.funcReturn(v)
+		return
+	}
+	if s.Value != nil {
+		p.errf(ast.ExprPos(s.Value), "return with a value outside of function")
+	}
+	p.ret()
+}
+
+// clear removes all variables and forgets open loops and GOSUBs, so that
+// a program can start over without restarting. Open files and error
+// trapping are not affected. It cannot be used inside a SUB or FUNCTION,
+// which have to return to their callers.
+func (p *Interpreter) clear(s *ast.ClearStmt) {
+	if sub := p.inside(); sub != "" {
+		p.errf(s.Clear.Pos, "clear: not allowed inside %s", sub)
+	}
+	p.Vars = make(map[string]Value)
+	p.Subs = p.Subs[:0]
+	p.Fors = p.Fors[:0]
+	p.Whiles = p.Whiles[:0]
+}
+
+// inside returns the name of the outermost SUB or FUNCTION being run, or
+// "" if there is none.
+func (p *Interpreter) inside() string {
+	for _, f := range p.Subs {
+		if f.Sub != "" {
+			return f.Sub
+		}
+	}
+	return ""
+}
+
+// pop leaves the current GOSUB without returning, so that it can GOTO
+// elsewhere without the frame piling up. Its locals are restored as by
+// RETURN.
+func (p *Interpreter) pop(s *ast.PopStmt) {
+	if len(p.Subs) == 0 {
+		p.errf(s.Pop.Pos, "pop without gosub")
+	}
+	if f := p.Subs[len(p.Subs)-1]; f.Sub != "" {
+		p.errf(s.Pop.Pos, "pop: cannot leave %s without returning", f.Sub)
 	}
-	p.PC = p.Subs[len(p.Subs)-1]
+	pc := p.PC
+	p.ret()
+	p.PC = pc
+}
+
+// ret pops the current frame, restoring the variables it saved.
+func (p *Interpreter) ret() {
+	f := p.Subs[len(p.Subs)-1]
 	p.Subs = p.Subs[:len(p.Subs)-1]
+	for name, v := range f.Saved {
+		if v == nil {
+			p.unsetVar(name)
+		} else {
+			p.setVar(name, v)
+		}
+	}
+	p.PC = f.Return
+}
+
+// local shadows variables for the rest of the current GOSUB, SUB or
+// FUNCTION, so recursive calls each have their own. Each local starts out
+// as zero; declaring a variable local twice in the same frame, or
+// declaring a parameter local, resets it without losing the caller's
+// value.
+func (p *Interpreter) local(s *ast.LocalStmt) {
+	if len(p.Subs) == 0 {
+		p.errf(s.Local.Pos, "local outside of gosub, sub or function")
+	}
+	f := &p.Subs[len(p.Subs)-1]
+	if f.Saved == nil {
+		f.Saved = make(map[string]Value)
+	}
+	for _, v := range s.Vars {
+		if _, saved := f.Saved[v.Name]; !saved {
+			f.Saved[v.Name] = p.Vars[v.Name]
+		}
+		if strings.HasSuffix(v.Name, "$") {
+			p.setVar(v.Name, String(""))
+		} else {
+			p.setVar(v.Name, Int(0))
+		}
+	}
 }
 
 func (p *Interpreter) assign(s *ast.LetStmt) {
-	p.Vars[s.Var.Name] = p.expr(s.Value)
+	if s.Index == nil {
+		p.setVar(s.Var.Name, p.expr(s.Value))
+		return
+	}
+
+	a, ok := p.Vars[s.Var.Name].(*Array)
+	if !ok {
+		p.errf(s.Var.Pos, "%v is not an array", s.Var.Name)
+	}
+	i := p.element(a, s.Var, s.Index)
+	v := p.expr(s.Value)
+	if (v.Kind() == StringKind) != (a.Elems[i].Kind() == StringKind) {
+		p.errf(ast.ExprPos(s.Value), "%w: cannot assign %v to element of %v", ErrTypeMismatch, v.Kind(), s.Var.Name)
+	}
+	a.Elems[i] = v
+	if p.VarChanged != nil {
+		p.VarChanged(s.Var.Name, a)
+	}
+	p.emit(Event{Kind: EventAssigned, Name: s.Var.Name, Value: a})
 }
 
 func (p *Interpreter) print(s *ast.PrintStmt) {
-	w := p.Mach
+	if s.Channel != nil {
+		c := p.fileChannel(s.Channel)
+		if c.w == nil {
+			p.errf(ast.ExprPos(s.Channel), "print: #%d is not open for output", p.int(s.Channel))
+		}
+		p.out = c
+		defer func() { p.out = nil }()
+	}
+
 	for _, arg := range s.Args {
 		switch arg := arg.(type) {
-		case *ast.BinaryExpr:
-			fmt.Fprint(w, p.expr(arg))
-		case *ast.ParenExpr:
-			fmt.Fprint(w, p.expr(arg))
-		case ast.String:
-			fmt.Fprint(w, arg.Value)
-		case ast.Variable:
-			fmt.Fprint(w, p.expr(arg))
-		case ast.Number:
-			fmt.Fprint(w, p.expr(arg))
 		case ast.Punct:
 			switch arg.Type {
 			case lex.COMMA:
-				fmt.Fprint(w, " ")
+				if p.ZoneWidth <= 0 {
+					p.write(" ")
+				} else {
+					p.write(strings.Repeat(" ", p.ZoneWidth-p.column()%p.ZoneWidth))
+				}
 			case lex.SEMICOLON:
 			default:
-				p.errf("%v: unknown print argument %T", s.Label, arg)
+				p.errf(s.Label.Pos, "unknown print argument %T", arg)
+			}
+		case *ast.CallExpr:
+			if !p.printFunc(arg) {
+				p.write(p.expr(arg).String())
 			}
 		default:
-			p.errf("%v: unknown print argument %T", s.Label, arg)
+			p.write(p.expr(arg).String())
+		}
+	}
+
+	if n := len(s.Args); p.PrintNewline && (n == 0 || !isPunct(s.Args[n-1])) {
+		p.write("\n")
+	}
+}
+
+func isPunct(e ast.Expr) bool {
+	_, ok := e.(ast.Punct)
+	return ok
+}
+
+// maxSpaces limits the argument of TAB and SPC so that a program cannot
+// exhaust the host's memory with the spaces of a single PRINT.
+const maxSpaces = 1 << 16
+
+// printFunc handles the TAB and SPC print items, which only make sense
+// inside PRINT since they depend on the output column. TAB(n) moves to
+// column n, counting from 1, starting a new line if the output is already
+// past it.
+func (p *Interpreter) printFunc(e *ast.CallExpr) bool {
+	name := strings.ToUpper(e.Func.Name)
+	if name != "TAB" && name != "SPC" {
+		return false
+	}
+	if len(e.Args) != 1 {
+		p.errk(e.Lparen.Pos, ErrArgumentCount, "%s: wrong number of arguments, expected %s(n)", name, name)
+	}
+	n := p.int(e.Args[0])
+	if n < 0 || n > maxSpaces {
+		p.errf(ast.ExprPos(e.Args[0]), "%s: %d out of range", name, n)
+	}
+
+	if name == "TAB" {
+		if n > 0 {
+			n--
+		}
+		if int64(p.column()) > n {
+			p.write("\n")
+		}
+		n -= int64(p.column())
+	}
+	p.write(strings.Repeat(" ", int(n)))
+	return true
+}
+
+// write writes s to the Mach, or to the file PRINT # is writing to,
+// keeping track of the output column.
+func (p *Interpreter) write(s string) {
+	if p.out != nil {
+		if err := p.out.write(s); err != nil {
+			panic(fmt.Errorf("print: %w", err))
 		}
+		return
+	}
+	p.page(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = len(s) - i - 1
+	} else {
+		p.col += len(s)
+	}
+}
+
+// column returns the column output is at, counting from 0.
+func (p *Interpreter) column() int {
+	if p.out != nil {
+		return p.out.col
+	}
+	return p.col
+}
+
+// ExtData returns the data an extension package stored under key with
+// SetExtData, or nil. Keys should be of an unexported type of the
+// extension package so they cannot collide.
+func (p *Interpreter) ExtData(key interface{}) interface{} {
+	return p.ext[key]
+}
+
+// SetExtData stores per-interpreter data for an extension package.
+func (p *Interpreter) SetExtData(key, value interface{}) {
+	if p.ext == nil {
+		p.ext = make(map[interface{}]interface{})
 	}
+	p.ext[key] = value
 }
 
-func truth(x bool) int64 {
-	if x {
-		return 1
+// positioned converts a recovered panic into an error carrying the
+// position of the statement that caused it, so that Go runtime errors
+// (such as an integer division by zero) do not escape without context.
+func positioned(s ast.Stmt, e interface{}) error {
+	switch e := e.(type) {
+	case *ast.Error:
+		return e
+	case *parse.Error:
+		return e
+	case error:
+		return &ast.Error{Pos: s.Pos(), Err: e}
+	default:
+		return &ast.Error{Pos: s.Pos(), Err: fmt.Errorf("%v", e)}
 	}
-	return 0
 }
 
-func (p *Interpreter) expr(e ast.Expr) int64 {
-	var n int64
+func (p *Interpreter) expr(e ast.Expr) Value {
 	switch e := e.(type) {
 	case *ast.BinaryExpr:
-		l := p.expr(e.X)
-		r := p.expr(e.Y)
 		switch e.Op.Type {
-		case lex.PLUS:
-			n = l + r
-		case lex.MINUS:
-			n = l - r
-		case lex.ASTR:
-			n = l * r
-		case lex.SLASH:
-			n = l / r
-		case lex.MOD:
-			n = l % r
-		case lex.AND:
-			n = l & r
-		case lex.OR:
-			n = l | r
-		case lex.XOR:
-			n = l ^ r
-		case lex.LT:
-			n = truth(l < r)
-		case lex.GT:
-			n = truth(l > r)
-		case lex.LEQ:
-			n = truth(l <= r)
-		case lex.GEQ:
-			n = truth(l >= r)
-		case lex.NEQ:
-			n = truth(l != r)
-		case lex.EQ:
-			n = truth(l == r)
-		default:
-			p.errf("%v: unknown binary operator %q", e.Op.Pos, e.Op.Type)
+		case lex.LAND:
+			return p.boolean(p.truth(e.X) && p.truth(e.Y))
+		case lex.LOR:
+			return p.boolean(p.truth(e.X) || p.truth(e.Y))
+		}
+		return p.binary(e.Op.Pos, e.Op.Type, p.expr(e.X), p.expr(e.Y))
+	case *ast.UnaryExpr:
+		v, err := Unary(e.Op.Type, p.expr(e.X))
+		if err != nil {
+			p.errf(e.Op.Pos, "%w", err)
+		}
+		if e.Op.Type == lex.NOT {
+			return p.boolean(v == Int(1))
 		}
+		return p.wrap(v)
 	case *ast.ParenExpr:
-		n = p.expr(e.X)
+		return p.expr(e.X)
+	case *ast.CallExpr:
+		if a, ok := p.Vars[e.Func.Name].(*Array); ok {
+			return a.Elems[p.element(a, e.Func, e.Args)]
+		}
+		return p.call(e)
 	case ast.Variable:
 		v, ok := p.Vars[e.Name]
 		if !ok {
-			p.errf("%v: unknown variable name %v", e.Pos, e.Name)
+			// Builtins without parameters, such as INKEY$, may be
+			// written without parentheses.
+			if b, ok := LookupBuiltin(e.Name); ok && len(b.Params) == b.Optional {
+				return p.call(&ast.CallExpr{Func: e})
+			}
+			return p.unassigned(e.Pos, e.Name)
 		}
-		n = v
+		return v
 	case ast.Number:
-		return e.Value
+		return p.wrap(Int(e.Value))
+	case ast.String:
+		return String(e.Value)
+	}
+	p.errf(ast.ExprPos(e), "unknown expression %T", e)
+	panic("unreachable")
+}
+
+func (p *Interpreter) call(e *ast.CallExpr) Value {
+	b, ok := LookupBuiltin(e.Func.Name)
+	if !ok {
+		switch strings.ToUpper(e.Func.Name) {
+		case "TAB", "SPC":
+			p.errf(e.Func.Pos, "%s is only allowed in PRINT", strings.ToUpper(e.Func.Name))
+		}
+		if v, found := p.hostFunc(e); found {
+			return v
+		}
+		if loc, found := p.locateProc(e.Func.Name); found {
+			return p.callFunc(e, loc)
+		}
+		p.errk(e.Func.Pos, ErrUndefinedFunction, "unknown function %v", e.Func.Name)
+	}
+	if b.Policy != "" && !p.Allow[b.Policy] {
+		p.errf(e.Func.Pos, "%s: not allowed without the %s policy", b.Name, b.Policy)
+	}
+	if isIIF(e) {
+		return p.iif(e)
+	}
+	n := len(e.Args)
+	if n < len(b.Params)-b.Optional || n > len(b.Params) && !b.Variadic {
+		p.errk(e.Lparen.Pos, ErrArgumentCount, "%s: wrong number of arguments, expected %s", b.Name, b.Syntax)
+	}
+
+	args := make([]Value, n)
+	for i, a := range e.Args {
+		v := p.expr(a)
+		if i < len(b.Params) {
+			var err error
+			if v, err = convert(b.Params[i], v); err != nil {
+				p.errf(ast.ExprPos(a), "%s: %w", b.Name, err)
+			}
+		}
+		args[i] = v
+	}
+
+	v, err := b.Func(p, args)
+	if err != nil {
+		p.errf(e.Func.Pos, "%s: %w", b.Name, err)
+	}
+	return p.wrap(v)
+}
+
+func (p *Interpreter) binary(pos scanner.Position, op lex.Token, x, y Value) Value {
+	v, err := Binary(op, x, y)
+	if err != nil {
+		p.errf(pos, "%w", err)
+	}
+	switch op {
+	case lex.LT, lex.GT, lex.LEQ, lex.GEQ, lex.NEQ, lex.EQ:
+		return p.boolean(v == Int(1))
+	}
+	return p.wrap(v)
+}
+
+// number evaluates e, which must produce a number.
+func (p *Interpreter) number(e ast.Expr) Value {
+	v := p.expr(e)
+	if !isNumber(v) {
+		p.errf(ast.ExprPos(e), "%w: expected number, got %v", ErrTypeMismatch, v.Kind())
+	}
+	return v
+}
+
+// int evaluates e as an integer, as needed for addresses and the like.
+func (p *Interpreter) int(e ast.Expr) int64 {
+	n, err := AsInt(p.expr(e))
+	if err != nil {
+		p.errf(ast.ExprPos(e), "%w", err)
 	}
 	return n
 }
 
-func Run(mach Mach, name string, src []byte) error {
+// truth evaluates e as a condition.
+func (p *Interpreter) truth(e ast.Expr) bool {
+	return p.cond(ast.ExprPos(e), p.expr(e))
+}
+
+func (p *Interpreter) cond(pos scanner.Position, v Value) bool {
+	t, err := IsTrue(v)
+	if err != nil {
+		p.errf(pos, "%w", err)
+	}
+	return t
+}
+
+// more appends the next statement from the stream being executed, if
+// any, to Lines. It reports whether a statement was added.
+func (p *Interpreter) more() (bool, error) {
+	if p.stream == nil {
+		return false, nil
+	}
+
+	line, err := p.stream.Line()
+	if err == io.EOF {
+		p.stream = nil
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	p.Lines = append(p.Lines, line)
+	p.link(len(p.Lines) - 1)
+	return true, nil
+}
+
+func (p *Interpreter) mustMore() bool {
+	more, err := p.more()
+	if err != nil {
+		panic(err)
+	}
+	return more
+}
+
+// target returns the index of the statement a jump goes to, the named
+// label if name is set and the numbered line otherwise.
+func (p *Interpreter) target(kw string, pos scanner.Position, line ast.Number, name ast.Variable) int {
+	if name.Name != "" {
+		loc, found := p.locateName(name.Name)
+		if !found {
+			p.errk(name.Pos, ErrBadGoto, "%s: label %v does not exist", kw, name.Name)
+		}
+		return loc
+	}
+	loc, found := p.locate(line.Value)
+	if !found {
+		p.errk(pos, ErrBadGoto, "%s: location %d does not exist%s", kw, line.Value, p.suggest(line.Value))
+	}
+	return loc
+}
+
+// suggest returns a hint naming the line numbers closest to line, for
+// errors about jumps to lines that do not exist.
+func (p *Interpreter) suggest(line int64) string {
+	var lines []int64
+	for n := range p.Locs {
+		lines = append(lines, n)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	dist := func(n int64) int64 {
+		if n > line {
+			return n - line
+		}
+		return line - n
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		di, dj := dist(lines[i]), dist(lines[j])
+		return di < dj || di == dj && lines[i] < lines[j]
+	})
+	if len(lines) > 3 {
+		lines = lines[:3]
+	}
+
+	var b strings.Builder
+	for i, n := range lines {
+		switch {
+		case i == 0:
+		case i == len(lines)-1:
+			b.WriteString(" or ")
+		default:
+			b.WriteString(", ")
+		}
+		fmt.Fprint(&b, n)
+	}
+	return " (did you mean " + b.String() + "?)"
+}
+
+// locateName is like locate for named labels.
+func (p *Interpreter) locateName(name string) (int, bool) {
+	for {
+		if loc, found := p.Names[name]; found {
+			return loc, true
+		}
+		if !p.mustMore() {
+			return 0, false
+		}
+	}
+}
+
+// locate returns the index of the statement with the given line number.
+// When executing a stream, lines not yet seen are read until the line is
+// found, so forward jumps work without the whole program in memory.
+func (p *Interpreter) locate(line int64) (int, bool) {
+	for {
+		if loc, found := p.Locs[line]; found {
+			return loc, true
+		}
+		if !p.mustMore() {
+			return 0, false
+		}
+	}
+}
+
+// Program is a parsed program ready to be loaded into an interpreter.
+// Metadata holds the text of directives other than options, such as
+// "title" and "author", for hosts that list programs. Includes holds the
+// paths of the libraries and files whose lines were added to the end of
+// the program.
+type Program struct {
+	Name     string
+	Lines    []ast.Stmt
+	Options  Options
+	Metadata map[string]string
+	Includes []string
+}
+
+// Compile parses src into a Program. The name is used in positions.
+func Compile(name string, src []byte) (*Program, error) {
+	return CompileWith(name, src, "")
+}
+
+// CompileWith is like Compile, but the options in override, given as in
+// a directive, are applied after the program's own and take precedence.
+func CompileWith(name string, src []byte, override string) (*Program, error) {
+	return CompileFiles(OSFileSystem{}, name, src, override)
+}
+
+// CompileFiles is like CompileWith, but the files named by INCLUDE are
+// read through files rather than from the operating system. If files is
+// nil, programs cannot include files, only libraries.
+func CompileFiles(files FileSystem, name string, src []byte, override string) (*Program, error) {
+	opts, meta, err := scanDirectives(name, src)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Parse(override); err != nil {
+		return nil, err
+	}
+
+	conf := lex.Config{Exponent: opts.Exponent}
 	var lexer lex.Tokenizer
-	lexer.Init(lex.Config{}, name, src)
+	lexer.Init(conf, name, src)
 	parser := parse.NewParser(&lexer)
-	interp := NewInterpreter(mach)
+	parser.AutoNumber = opts.AutoNumber
+	parser.TrueValue = opts.TrueValue
+
+	c := &compiler{
+		prog:     &Program{Name: name, Options: opts, Metadata: meta},
+		files:    files,
+		conf:     conf,
+		labels:   make(map[string]bool),
+		procs:    make(map[string]bool),
+		included: make(map[string]bool),
+		parents:  make(map[string]string),
+	}
+	if err := c.parse(parser); err != nil {
+		return nil, err
+	}
+	if err := c.include(); err != nil {
+		return nil, err
+	}
+	return c.prog, nil
+}
+
+// compiler holds what is declared across the files making up a program.
+type compiler struct {
+	prog     *Program
+	files    FileSystem
+	conf     lex.Config
+	labels   map[string]bool
+	procs    map[string]bool
+	included map[string]bool
+	parents  map[string]string
+	pending  []*ast.IncludeStmt
+}
 
+// parse adds the lines read by parser to the program.
+func (c *compiler) parse(parser *parse.Parser) error {
 	for {
 		line, err := parser.Line()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
 			return err
 		}
-		interp.Lines = append(interp.Lines, line)
+		switch l := line.(type) {
+		case *ast.LabelStmt:
+			if c.labels[l.Name.Name] {
+				return &parse.Error{Pos: l.Name.Pos, Err: fmt.Errorf("label %v redeclared", l.Name.Name)}
+			}
+			c.labels[l.Name.Name] = true
+		case *ast.SubStmt:
+			if err := declare(c.procs, l.Name); err != nil {
+				return err
+			}
+		case *ast.FunctionStmt:
+			if _, ok := LookupBuiltin(l.Name.Name); ok {
+				return &parse.Error{Pos: l.Name.Pos, Err: fmt.Errorf("function %v redeclares a builtin", l.Name.Name)}
+			}
+			if err := declare(c.procs, l.Name); err != nil {
+				return err
+			}
+		case *ast.IncludeStmt:
+			c.pending = append(c.pending, l)
+		}
+		c.prog.Lines = append(c.prog.Lines, line)
 	}
-	for i, s := range interp.Lines {
-		interp.Locs[s.Line()] = i
+}
+
+// declare adds the procedure name to procs, failing if it is already
+// there.
+func declare(procs map[string]bool, name ast.Variable) error {
+	key := strings.ToUpper(name.Name)
+	if procs[key] {
+		return &parse.Error{Pos: name.Pos, Err: fmt.Errorf("procedure %v redeclared", name.Name)}
 	}
+	procs[key] = true
+	return nil
+}
+
+// Load replaces the program being executed with prog and resets the
+// interpreter so that it starts from the first line.
+func (p *Interpreter) Load(prog *Program) {
+	p.Lines = prog.Lines
+	p.relink()
+	p.Reset()
+	p.Steps = 0
+	p.configure(prog)
+	p.emit(Event{Kind: EventStarted})
+}
 
-	interp.Reset()
-	for !interp.Halt {
-		err := interp.Step()
+// configure applies the options of prog, which has just been loaded.
+func (p *Interpreter) configure(prog *Program) {
+	p.progSteps = prog.Options.MaxSteps
+	if n := prog.Options.pageLength(); n > 0 {
+		p.PageLength = n
+	}
+	if n := prog.Options.IntWidth; n > 0 {
+		p.IntWidth = n
+	}
+	if n := prog.Options.TrueValue; n != 0 {
+		p.TrueValue = n
+	}
+	p.included = make(map[string]bool)
+	for _, path := range prog.Includes {
+		p.included[path] = true
+	}
+}
+
+// relink rebuilds the line number index after Lines changed.
+func (p *Interpreter) relink() {
+	p.Locs = make(map[int64]int)
+	p.Names = make(map[string]int)
+	p.Procs = make(map[string]int)
+	for i := range p.Lines {
+		p.link(i)
+	}
+}
+
+// link adds the i'th line to the line number, label and procedure
+// indexes.
+func (p *Interpreter) link(i int) {
+	s := p.Lines[i]
+	switch l := s.(type) {
+	case *ast.LabelStmt:
+		p.Names[l.Name.Name] = i
+		if !l.Numbered {
+			return
+		}
+	case *ast.SubStmt:
+		p.Procs[strings.ToUpper(l.Name.Name)] = i
+	case *ast.FunctionStmt:
+		p.Procs[strings.ToUpper(l.Name.Name)] = i
+	}
+	p.Locs[s.Line()] = i
+}
+
+// Run compiles and runs src on mach. It returns the interpreter, unless
+// the program failed to compile, so that hosts can inspect the state the
+// program ended in.
+func Run(mach Mach, name string, src []byte) (*Interpreter, error) {
+	prog, err := Compile(name, src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewInterpreter(mach)
+	return p, p.Run(prog)
+}
+
+// RunContext is like Run, but the program stops with the context's error
+// before the next statement once ctx is cancelled.
+func RunContext(ctx context.Context, mach Mach, name string, src []byte) (*Interpreter, error) {
+	prog, err := Compile(name, src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewInterpreter(mach)
+	return p, p.RunContext(ctx, prog)
+}
+
+// RunContext sets the context of p to ctx and runs prog.
+func (p *Interpreter) RunContext(ctx context.Context, prog *Program) error {
+	p.Context = ctx
+	return p.Run(prog)
+}
+
+// Run loads prog and executes it until it halts or fails.
+func (p *Interpreter) Run(prog *Program) error {
+	p.Load(prog)
+	return p.Continue()
+}
+
+// maxSteps returns the lower of MaxSteps and the program's maxsteps
+// option, ignoring those that are zero.
+func (p *Interpreter) maxSteps() int64 {
+	max := p.MaxSteps
+	if n := p.progSteps; n > 0 && (max <= 0 || n < max) {
+		max = n
+	}
+	return max
+}
+
+// RunStream executes a program read incrementally from r. Statements are
+// parsed only when execution reaches them or a jump refers to a line that
+// has not been read yet, so machine generated programs can be executed as
+// they are produced. A jump can only target lines already read or lines
+// further ahead in the stream. Only the directives in the comments before
+// the first statement apply, since the options they set are needed to
+// parse it.
+func RunStream(mach Mach, name string, r io.Reader) error {
+	return NewInterpreter(mach).RunStream(name, r)
+}
+
+// RunStream is like the RunStream function but uses p.
+func (p *Interpreter) RunStream(name string, r io.Reader) error {
+	return p.RunStreamWith(name, r, "")
+}
+
+// RunStreamWith is like RunStream, but the options in override, given as
+// in a directive, are applied after the program's own and take
+// precedence.
+func (p *Interpreter) RunStreamWith(name string, r io.Reader, override string) error {
+	br := bufio.NewReader(r)
+	header, err := readHeader(br)
+	if err != nil {
+		return err
+	}
+	opts, meta, err := scanDirectives(name, []byte(header))
+	if err != nil {
+		return err
+	}
+	if err := opts.Parse(override); err != nil {
+		return err
+	}
+
+	var lexer lex.Tokenizer
+	lexer.InitReader(lex.Config{Exponent: opts.Exponent}, name, io.MultiReader(strings.NewReader(header), br))
+	p.Lines = nil
+	p.relink()
+	p.Reset()
+	p.configure(&Program{Name: name, Options: opts, Metadata: meta})
+	p.stream = parse.NewParser(&lexer)
+	p.stream.AutoNumber = opts.AutoNumber
+	p.stream.TrueValue = p.TrueValue
+	p.emit(Event{Kind: EventStarted})
+
+	for !p.Halt {
+		err := p.Step()
 		if err != nil {
 			return err
 		}
@@ -306,50 +1532,62 @@ func Run(mach Mach, name string, src []byte) error {
 }
 
 func Repl(mach Mach, r io.Reader) error {
+	return NewInterpreter(mach).Repl(r)
+}
+
+// Repl reads lines from r, adding numbered lines to the program and
+// executing the others at once.
+func (p *Interpreter) Repl(r io.Reader) error {
 	var lexer lex.Tokenizer
 	parser := parse.NewParser(&lexer)
-	interp := NewInterpreter(mach)
 
-	w := mach
-	scan := bufio.NewScanner(r)
+	w := p.Mach
+	rd := bufio.NewReader(r)
+	p.rd = rd
 
 loop:
 	for {
 		fmt.Fprint(w, "> ")
-		if !scan.Scan() {
+		line, err := rd.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
 			fmt.Fprintln(w)
 			break
 		}
-		line := strings.TrimSpace(scan.Text())
+		line = strings.TrimSpace(line)
 
 		switch line {
 		case "p":
-			for _, s := range interp.Lines {
-				fmt.Println(s)
-			}
+			ek(p.List())
 			continue loop
 
 		case "q":
 			break loop
 		}
+		if f := strings.Fields(line); len(f) > 0 && strings.EqualFold(f[0], "help") {
+			help(w, strings.Join(f[1:], " "))
+			continue loop
+		}
 
 		lexer.Init(lex.Config{}, "", []byte(line))
 		parser.Reset()
+		parser.TrueValue = p.TrueValue
 		stmt, err := parser.Line()
 		if err == io.EOF || ek(err) {
 			continue
 		}
 
-		addLine(interp, stmt)
+		addLine(p, stmt)
 		switch stmt.(type) {
 		case *ast.GosubStmt:
-			ek(replRun(interp))
+			ek(replRun(p))
 		case *ast.GotoStmt:
-			ek(replRun(interp))
+			ek(replRun(p))
 		case *ast.NextStmt:
+		case *ast.WhileStmt:
+		case *ast.WendStmt:
 		case *ast.EndStmt:
 		default:
-			ek(interp.Eval(stmt))
+			ek(p.Eval(stmt))
 		}
 	}
 
@@ -371,10 +1609,7 @@ func addLine(p *Interpreter, s ast.Stmt) {
 		p.Lines = append(p.Lines[:n], p.Lines[n+1:]...)
 	}
 	p.Lines = append(p.Lines, s)
-	p.Locs = make(map[int64]int)
-	for i, s := range p.Lines {
-		p.Locs[s.Line()] = i
-	}
+	p.relink()
 	p.PC = len(p.Lines) - 1
 }
 
@@ -0,0 +1,185 @@
+// Package interptest provides helpers for writing Go tests of BASIC
+// programs, such as scripts embedded in a larger application.
+//
+//	func TestGreet(t *testing.T) {
+//		r := interptest.RunString(t, src, &interptest.Options{Input: "bob\n"})
+//		interptest.Golden(t, "testdata/greet.golden", r.Output)
+//	}
+//
+// Golden files are rewritten with the output seen when the tests are run
+// with the -ubasic.update flag.
+package interptest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+var update = flag.Bool("ubasic.update", false, "rewrite golden files with the output seen")
+
+// DefaultMaxSteps is the step limit used when Options does not give one.
+const DefaultMaxSteps = 1000000
+
+// Options configure RunString. The zero value runs the program with no
+// input and the default step limit.
+type Options struct {
+	// Name is the file name used in error positions.
+	Name string
+
+	// Input is read by INPUT statements.
+	Input string
+
+	// MaxSteps limits the statements executed; zero means
+	// DefaultMaxSteps and a negative value means no limit.
+	MaxSteps int64
+
+	// Setup, if not nil, is called with the interpreter before the
+	// program runs, to bind host functions, allow policies and the like.
+	Setup func(p *interp.Interpreter)
+
+	// WantErr makes a runtime error part of the result instead of
+	// failing the test.
+	WantErr bool
+
+	// Context, if not nil, is the context the program runs with.
+	Context context.Context
+}
+
+// Result is what a program did when run by RunString.
+type Result struct {
+	// Output is everything the program wrote.
+	Output string
+
+	// Vars holds the variables as the program ended.
+	Vars map[string]interp.Value
+
+	// Steps is the number of statements executed.
+	Steps int64
+
+	// Err is the runtime error the program stopped with, if WantErr was
+	// set.
+	Err error
+
+	// Interp is the interpreter the program ran on.
+	Interp *interp.Interpreter
+
+	// Mach is the machine the program ran on.
+	Mach *Mach
+}
+
+// Mach is the machine programs run on. Output is collected, input comes
+// from a string and memory is private to each program.
+type Mach struct {
+	Out    bytes.Buffer
+	In     *strings.Reader
+	Values map[int64]int64
+}
+
+func (m *Mach) Write(b []byte) (int, error) { return m.Out.Write(b) }
+func (m *Mach) Read(b []byte) (int, error)  { return m.In.Read(b) }
+func (m *Mach) Peek(addr int64) int64       { return m.Values[addr] }
+func (m *Mach) Poke(addr, value int64)      { m.Values[addr] = value }
+
+// RunString compiles and runs src, failing the test if it does not
+// compile, runs into the step limit or, unless WantErr is set, stops with
+// an error.
+func RunString(t testing.TB, src string, opts *Options) *Result {
+	t.Helper()
+	if opts == nil {
+		opts = &Options{}
+	}
+	name := opts.Name
+	if name == "" {
+		name = t.Name() + ".bas"
+	}
+
+	prog, err := interp.Compile(name, []byte(src))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	max := opts.MaxSteps
+	if max == 0 {
+		max = DefaultMaxSteps
+	}
+	if n := prog.Options.MaxSteps; n > 0 && (max < 0 || n < max) {
+		max = n
+	}
+
+	m := &Mach{
+		In:     strings.NewReader(opts.Input),
+		Values: make(map[int64]int64),
+	}
+	p := interp.NewInterpreter(m)
+	p.Context = opts.Context
+	if opts.Setup != nil {
+		opts.Setup(p)
+	}
+
+	r := &Result{Interp: p, Mach: m}
+	p.Load(prog)
+	for !p.Halt && r.Err == nil {
+		if max > 0 && r.Steps >= max {
+			t.Fatalf("run: %s: step %v (%d)", name, interp.ErrLimit, max)
+		}
+		r.Err = p.Step()
+		r.Steps++
+	}
+	r.Output = m.Out.String()
+	r.Vars = p.Vars
+
+	if r.Err != nil && !opts.WantErr {
+		t.Fatalf("run: %v\noutput:\n%s", r.Err, r.Output)
+	}
+	if r.Err == nil && opts.WantErr {
+		t.Fatalf("run: %s: no error", name)
+	}
+	return r
+}
+
+// Golden compares got with the contents of the golden file at path,
+// failing the test if they differ. With the -ubasic.update flag the file
+// is written with got instead.
+func Golden(t testing.TB, path string, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("%s does not exist; run with -ubasic.update to create it", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// Var returns the value the program left in the named variable, failing
+// the test if there is no such variable.
+func (r *Result) Var(t testing.TB, name string) interp.Value {
+	t.Helper()
+	v, ok := r.Vars[name]
+	if !ok {
+		t.Fatalf("variable %s is not set", name)
+	}
+	return v
+}
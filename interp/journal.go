@@ -0,0 +1,225 @@
+package interp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrReplay is wrapped by the error reported when a program being
+// replayed asks for an effect other than the one the journal has next,
+// meaning it took another path than when it was recorded.
+var ErrReplay = errors.New("replay diverged from journal")
+
+// Effect is an effect of the world outside on a program: the value of a
+// PEEK, a line read by INPUT, a number drawn by RND, a key read by
+// INKEY$ or GET, or the result of calling the host.
+type Effect struct {
+	// Kind is "peek", "input", "rnd", "key", "call" for a function bound
+	// with Bind or RegisterFunc, or "sys" for a routine bound with
+	// BindRoutine.
+	Kind string `json:"kind"`
+
+	// Arg is the address of a peek or a routine or the argument of RND,
+	// Bits the size of a sized peek, and Name the name of the function
+	// called.
+	Arg  int64  `json:"arg,omitempty"`
+	Bits int    `json:"bits,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// Type is the kind of value a call returned, "int", "float" or
+	// "string", or "" if it returned none. Value holds an int, and Text
+	// a string or a float written out.
+	Type string `json:"type,omitempty"`
+
+	// Value is the number read or drawn, or the key read, or -1 if there
+	// was none, and Text is the line read.
+	Value int64  `json:"value,omitempty"`
+	Text  string `json:"text,omitempty"`
+
+	// Err is the message of the error the effect failed with, if any,
+	// and Is the message of the sentinel error, such as io.EOF, it
+	// wraps, so that it is replayed as an error matching the sentinel.
+	Err string `json:"err,omitempty"`
+	Is  string `json:"is,omitempty"`
+}
+
+// what describes the effect for errors, as in "peek 53280" or "call FOO".
+func (e *Effect) what() string {
+	if e.Name != "" {
+		return e.Kind + " " + e.Name
+	}
+	return fmt.Sprintf("%s %d", e.Kind, e.Arg)
+}
+
+// setResult records v as the result of a call.
+func (e *Effect) setResult(v Value) {
+	switch v := v.(type) {
+	case Int:
+		e.Type, e.Value = "int", int64(v)
+	case Float:
+		e.Type, e.Text = "float", strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case String:
+		e.Type, e.Text = "string", string(v)
+	}
+}
+
+// result returns the result of a call, or nil if it returned none.
+func (e *Effect) result() Value {
+	switch e.Type {
+	case "int":
+		return Int(e.Value)
+	case "float":
+		f, _ := strconv.ParseFloat(e.Text, 64)
+		return Float(f)
+	case "string":
+		return String(e.Text)
+	}
+	return nil
+}
+
+// sentinels are the errors effects are replayed as wrapping, in the
+// order they are looked for.
+var sentinels = []error{
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	context.Canceled,
+	context.DeadlineExceeded,
+	ErrAddress,
+	ErrStepLimit,
+	ErrTimeLimit,
+	ErrLimit,
+	ErrTypeMismatch,
+	ErrArgumentCount,
+	ErrIndex,
+	ErrDivisionByZero,
+}
+
+// setErr records err as the error e failed with.
+func (e *Effect) setErr(err error) {
+	e.Err = err.Error()
+	for _, s := range sentinels {
+		if errors.Is(err, s) {
+			e.Is = s.Error()
+			return
+		}
+	}
+}
+
+// err returns the error e failed with: the sentinel itself if that was
+// the error, or an error with the message recorded wrapping it.
+func (e *Effect) err() error {
+	for _, s := range sentinels {
+		if e.Is != s.Error() {
+			continue
+		}
+		if e.Err == e.Is {
+			return s
+		}
+		return &replayedError{msg: e.Err, err: s}
+	}
+	return errors.New(e.Err)
+}
+
+// replayedError is a recorded error that wrapped a sentinel.
+type replayedError struct {
+	msg string
+	err error
+}
+
+func (e *replayedError) Error() string { return e.msg }
+func (e *replayedError) Unwrap() error { return e.err }
+
+// Journal is a log of the effects on a run of a program, kept so that
+// the run can be reproduced exactly, as when a program that reads devices
+// or random numbers fails in a way that is hard to make happen again.
+type Journal struct {
+	Effects []Effect
+
+	replay bool
+	next   int
+}
+
+// ReadJournal reads a journal written by WriteTo.
+func ReadJournal(r io.Reader) (*Journal, error) {
+	j := &Journal{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var e Effect
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("journal: %w", err)
+		}
+		j.Effects = append(j.Effects, e)
+	}
+	return j, sc.Err()
+}
+
+// WriteTo writes the journal to w as JSON, an effect per line.
+func (j *Journal) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	enc := json.NewEncoder(cw)
+	for _, e := range j.Effects {
+		if err := enc.Encode(e); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// take returns the next effect of a journal being replayed, which must be
+// of the same kind and argument as want.
+func (j *Journal) take(want Effect) (Effect, error) {
+	if j.next >= len(j.Effects) {
+		return want, fmt.Errorf("%w: %s after the end of the journal", ErrReplay, want.Kind)
+	}
+	e := j.Effects[j.next]
+	if e.Kind != want.Kind || e.Arg != want.Arg || e.Bits != want.Bits || e.Name != want.Name {
+		return want, fmt.Errorf("%w: effect %d is %s, not %s", ErrReplay, j.next+1, e.what(), want.what())
+	}
+	j.next++
+	if e.Err != "" {
+		return e, e.err()
+	}
+	return e, nil
+}
+
+// RecordEffects makes the interpreter log the effects on the programs it
+// runs to a new journal, which it returns.
+func (p *Interpreter) RecordEffects() *Journal {
+	p.journal = &Journal{}
+	return p.journal
+}
+
+// ReplayEffects makes the interpreter take the effects on the programs
+// it runs from j, from its start, instead of from the Mach, the host and
+// Rand, so that a recorded run happens again the same way.
+func (p *Interpreter) ReplayEffects(j *Journal) {
+	j.replay = true
+	j.next = 0
+	p.journal = j
+}
+
+// effect returns e completed by do, which brings about the effect, and
+// logs it if effects are being recorded, or returns the effect from the
+// journal in place of calling do if they are being replayed.
+func (p *Interpreter) effect(e Effect, do func(e *Effect) error) (Effect, error) {
+	j := p.journal
+	switch {
+	case j == nil:
+		err := do(&e)
+		return e, err
+	case j.replay:
+		return j.take(e)
+	}
+	err := do(&e)
+	if err != nil {
+		e.setErr(err)
+	}
+	j.Effects = append(j.Effects, e)
+	return e, err
+}
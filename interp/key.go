@@ -0,0 +1,68 @@
+package interp
+
+import (
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// KeyMach is implemented by machines with a keyboard that can be polled.
+// Key returns the next key pressed, or false if none is waiting; it must
+// not block. Machines without a keyboard never have a key pressed.
+type KeyMach interface {
+	Mach
+	Key() (rune, bool)
+}
+
+// keyFrom polls m for a key for machines that wrap it.
+func keyFrom(m Mach) (rune, bool) {
+	if k, ok := m.(KeyMach); ok {
+		return k.Key()
+	}
+	return 0, false
+}
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "INKEY$",
+		Syntax: "INKEY$()",
+		Doc:    "the next key pressed, or \"\" if there is none",
+		Result: StringKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			if r, ok := p.key(); ok {
+				return String(r), nil
+			}
+			return String(""), nil
+		},
+	})
+}
+
+func (p *Interpreter) key() (rune, bool) {
+	e, err := p.effect(Effect{Kind: "key"}, func(e *Effect) error {
+		e.Value = -1
+		if k, ok := p.Mach.(KeyMach); ok {
+			if r, ok := k.Key(); ok {
+				e.Value = int64(r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return rune(e.Value), e.Value >= 0
+}
+
+func (p *Interpreter) get(s *ast.GetStmt) {
+	r, ok := p.key()
+	switch {
+	case strings.HasSuffix(s.Var.Name, "$") && ok:
+		p.setVar(s.Var.Name, String(r))
+	case strings.HasSuffix(s.Var.Name, "$"):
+		p.setVar(s.Var.Name, String(""))
+	case ok:
+		p.setVar(s.Var.Name, Int(r))
+	default:
+		p.setVar(s.Var.Name, Int(0))
+	}
+}
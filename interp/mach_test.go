@@ -0,0 +1,43 @@
+package interp_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qeedquan/go-ubasic/interp"
+	"github.com/qeedquan/go-ubasic/interp/interptest"
+)
+
+// TestWrappedInput checks that machines adding memory to another, as
+// -memfile does, pass its input on to INPUT.
+func TestWrappedInput(t *testing.T) {
+	prog, err := interp.Compile("input.bas", []byte("10 input a\n20 print a * 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "mem.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, wrap := range []struct {
+		name string
+		mach func(interp.Mach) interp.Mach
+	}{
+		{"MemMach", func(m interp.Mach) interp.Mach { return interp.NewMemMach(m, 16) }},
+		{"FileMach", func(m interp.Mach) interp.Mach { return &interp.FileMach{Mach: m, File: f} }},
+	} {
+		m := &interptest.Mach{In: strings.NewReader("21\n"), Values: make(map[int64]int64)}
+		p := interp.NewInterpreter(wrap.mach(m))
+		if err := p.Run(prog); err != nil {
+			t.Errorf("%s: %v", wrap.name, err)
+			continue
+		}
+		if got, want := m.Out.String(), "? 42\n"; got != want {
+			t.Errorf("%s: output %q, want %q", wrap.name, got, want)
+		}
+	}
+}
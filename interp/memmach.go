@@ -0,0 +1,137 @@
+package interp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrAddress is wrapped by the errors of accesses to memory that does not
+// exist.
+var ErrAddress = errors.New("address out of range")
+
+// MemMach is a machine with a fixed amount of memory made of bytes, as on
+// the microcontrollers uBASIC was written for. PEEK and POKE access one
+// byte, POKE keeping only the low 8 bits of the value, and PEEK16 and the
+// like access several in the byte order Order, little endian if it is
+// nil. Accessing an address outside of Mem is an error. Output goes to,
+// and input comes from, the underlying Mach.
+type MemMach struct {
+	Mach
+	Mem   []byte
+	Order binary.ByteOrder
+}
+
+// NewMemMach returns a MemMach with size bytes of memory, all zero, that
+// writes output to mach.
+func NewMemMach(mach Mach, size int) *MemMach {
+	return &MemMach{Mach: mach, Mem: make([]byte, size)}
+}
+
+// bytes returns the n bytes of memory at addr, or an error if they do
+// not all exist.
+func (m *MemMach) bytes(addr int64, n int) ([]byte, error) {
+	if addr < 0 || addr > int64(len(m.Mem)-n) {
+		return nil, fmt.Errorf("%w: %d is outside of the %d bytes of memory", ErrAddress, addr, len(m.Mem))
+	}
+	return m.Mem[addr : addr+int64(n)], nil
+}
+
+// mustBytes is like bytes, but panics with the error. The interpreter
+// reports it at the statement accessing the memory.
+func (m *MemMach) mustBytes(addr int64, n int) []byte {
+	b, err := m.bytes(addr, n)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (m *MemMach) order() binary.ByteOrder {
+	if m.Order == nil {
+		return binary.LittleEndian
+	}
+	return m.Order
+}
+
+func (m *MemMach) Peek(addr int64) int64 {
+	return int64(m.mustBytes(addr, 1)[0])
+}
+
+func (m *MemMach) Poke(addr, value int64) {
+	m.mustBytes(addr, 1)[0] = byte(value)
+}
+
+func (m *MemMach) PeekErr(addr int64) (int64, error) {
+	b, err := m.bytes(addr, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int64(b[0]), nil
+}
+
+func (m *MemMach) PokeErr(addr, value int64) error {
+	b, err := m.bytes(addr, 1)
+	if err != nil {
+		return err
+	}
+	b[0] = byte(value)
+	return nil
+}
+
+func (m *MemMach) PeekSized(addr int64, bits int) int64 {
+	v, err := m.PeekSizedErr(addr, bits)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (m *MemMach) PokeSized(addr int64, bits int, value int64) {
+	if err := m.PokeSizedErr(addr, bits, value); err != nil {
+		panic(err)
+	}
+}
+
+func (m *MemMach) PeekSizedErr(addr int64, bits int) (int64, error) {
+	b, err := m.bytes(addr, bits/8)
+	if err != nil {
+		return 0, err
+	}
+	switch bits {
+	case 16:
+		return int64(m.order().Uint16(b)), nil
+	case 32:
+		return int64(m.order().Uint32(b)), nil
+	}
+	return int64(b[0]), nil
+}
+
+func (m *MemMach) PokeSizedErr(addr int64, bits int, value int64) error {
+	b, err := m.bytes(addr, bits/8)
+	if err != nil {
+		return err
+	}
+	switch bits {
+	case 16:
+		m.order().PutUint16(b, uint16(value))
+	case 32:
+		m.order().PutUint32(b, uint32(value))
+	default:
+		b[0] = byte(value)
+	}
+	return nil
+}
+
+// Read, ReadLine and Key pass on the input of the underlying Mach.
+func (m *MemMach) Read(b []byte) (int, error) { return readFrom(m.Mach, b) }
+func (m *MemMach) ReadLine() (string, error)  { return readLineFrom(m.Mach) }
+func (m *MemMach) Key() (rune, bool)          { return keyFrom(m.Mach) }
+
+// Flush flushes the underlying Mach if it buffers output.
+func (m *MemMach) Flush() error {
+	if f, ok := m.Mach.(FlushMach); ok {
+		return f.Flush()
+	}
+	return nil
+}
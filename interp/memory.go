@@ -0,0 +1,160 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// SizedMach is implemented by machines whose memory is made of bytes and
+// that read and write values wider than a byte themselves, such as in the
+// byte order of the machine emulated. On other machines PEEK16, POKE16
+// and the like access each byte with Peek and Poke, least significant
+// byte first.
+type SizedMach interface {
+	Mach
+	PeekSized(addr int64, bits int) int64
+	PokeSized(addr int64, bits int, value int64)
+}
+
+// ErrMach is implemented by machines that can refuse accesses to their
+// memory, such as to addresses where there is none. The interpreter uses
+// PeekErr and PokeErr instead of Peek and Poke when a machine has them,
+// and reports their errors where the program accessed the memory.
+type ErrMach interface {
+	Mach
+	PeekErr(addr int64) (int64, error)
+	PokeErr(addr, value int64) error
+}
+
+// SizedErrMach is implemented by SizedMachs that can refuse accesses, as
+// ErrMach is by machines accessed a byte at a time. The interpreter uses
+// PeekSizedErr and PokeSizedErr instead of PeekSized and PokeSized when a
+// machine has them.
+type SizedErrMach interface {
+	SizedMach
+	PeekSizedErr(addr int64, bits int) (int64, error)
+	PokeSizedErr(addr int64, bits int, value int64) error
+}
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "PEEK",
+		Syntax: "PEEK(addr)",
+		Doc:    "the value at addr in the machine's memory, as read by the PEEK statement",
+		Params: []Kind{IntKind},
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return p.peek(int64(args[0].(Int)))
+		},
+	})
+	for _, bits := range []int{8, 16, 32} {
+		bits := bits
+		RegisterBuiltin(&Builtin{
+			Name:   fmt.Sprintf("PEEK%d", bits),
+			Syntax: fmt.Sprintf("PEEK%d(addr)", bits),
+			Doc:    fmt.Sprintf("the unsigned %d bit value in the %d bytes from addr", bits, bits/8),
+			Params: []Kind{IntKind},
+			Result: IntKind,
+			Func: func(p *Interpreter, args []Value) (Value, error) {
+				return p.peekSized(int64(args[0].(Int)), bits)
+			},
+		})
+	}
+}
+
+// machPeek reads the value at addr from m.
+func machPeek(m Mach, addr int64) (int64, error) {
+	if e, ok := m.(ErrMach); ok {
+		return e.PeekErr(addr)
+	}
+	return m.Peek(addr), nil
+}
+
+// machPoke writes the value at addr to m.
+func machPoke(m Mach, addr, value int64) error {
+	if e, ok := m.(ErrMach); ok {
+		return e.PokeErr(addr, value)
+	}
+	m.Poke(addr, value)
+	return nil
+}
+
+// machPeekSized reads the value of the given number of bits from addr in
+// m, a byte at a time, least significant first, unless it has sized
+// accesses.
+func machPeekSized(m Mach, addr int64, bits int) (int64, error) {
+	switch s := m.(type) {
+	case SizedErrMach:
+		return s.PeekSizedErr(addr, bits)
+	case SizedMach:
+		return s.PeekSized(addr, bits), nil
+	}
+	var v int64
+	for i := 0; i < bits/8; i++ {
+		b, err := machPeek(m, addr+int64(i))
+		if err != nil {
+			return 0, err
+		}
+		v |= (b & 0xff) << (8 * uint(i))
+	}
+	return v, nil
+}
+
+// machPokeSized writes value to the given number of bits at addr in m as
+// machPeekSized reads them.
+func machPokeSized(m Mach, addr int64, bits int, value int64) error {
+	switch s := m.(type) {
+	case SizedErrMach:
+		return s.PokeSizedErr(addr, bits, value)
+	case SizedMach:
+		s.PokeSized(addr, bits, value)
+		return nil
+	}
+	for i := 0; i < bits/8; i++ {
+		if err := machPoke(m, addr+int64(i), value>>(8*uint(i))&0xff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// peek reads the value at addr, wrapped to the integer width.
+func (p *Interpreter) peek(addr int64) (Value, error) {
+	e, err := p.effect(Effect{Kind: "peek", Arg: addr}, func(e *Effect) (err error) {
+		e.Value, err = machPeek(p.Mach, addr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.wrap(Int(e.Value)), nil
+}
+
+// peekSized reads the value of the given number of bits from addr.
+func (p *Interpreter) peekSized(addr int64, bits int) (Value, error) {
+	e, err := p.effect(Effect{Kind: "peek", Arg: addr, Bits: bits}, func(e *Effect) (err error) {
+		e.Value, err = machPeekSized(p.Mach, addr, bits)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.wrap(Int(e.Value & (1<<uint(bits) - 1))), nil
+}
+
+func (p *Interpreter) poke(s *ast.PokeStmt) {
+	addr := p.int(s.Addr)
+	v := int64(p.wrap(Int(p.int(s.Value))).(Int))
+	if s.Size == 0 {
+		if err := machPoke(p.Mach, addr, v); err != nil {
+			p.errf(ast.ExprPos(s.Addr), "poke: %w", err)
+		}
+		return
+	}
+
+	v &= 1<<uint(s.Size) - 1
+	if err := machPokeSized(p.Mach, addr, s.Size, v); err != nil {
+		p.errf(ast.ExprPos(s.Addr), "poke: %w", err)
+	}
+}
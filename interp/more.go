@@ -0,0 +1,84 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// morePrompt is written when output pauses at the end of a page.
+const morePrompt = "-- More --"
+
+// keyPoll is how often a KeyMach is polled while output is paused.
+const keyPoll = 10 * time.Millisecond
+
+// page writes s to the Mach, pausing at the end of each page if
+// pagination is on.
+func (p *Interpreter) page(s string) {
+	if !p.Paginate || p.PageLength < 2 {
+		io.WriteString(p.Mach, s)
+		return
+	}
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			io.WriteString(p.Mach, s)
+			return
+		}
+		io.WriteString(p.Mach, s[:i+1])
+		s = s[i+1:]
+
+		// The last row of the page is left for the prompt.
+		if p.rows++; p.rows >= p.PageLength-1 {
+			p.pause()
+		}
+	}
+}
+
+// pause writes the More prompt and waits for a key, or for a line if the
+// Mach has no keyboard, before starting a new page.
+func (p *Interpreter) pause() {
+	p.rows = 0
+	io.WriteString(p.Mach, morePrompt)
+
+	if k, ok := p.Mach.(KeyMach); ok {
+		t := time.NewTicker(keyPoll)
+		defer t.Stop()
+		for {
+			if _, ok := k.Key(); ok {
+				break
+			}
+			select {
+			case <-t.C:
+			case <-p.ctx().Done():
+				panic(fmt.Errorf("more: %w", p.ctx().Err()))
+			}
+		}
+		fmt.Fprintf(p.Mach, "\r%s\r", strings.Repeat(" ", len(morePrompt)))
+		return
+	}
+
+	if _, err := p.rawLine(); err != nil {
+		panic(fmt.Errorf("more: %w", err))
+	}
+}
+
+// List writes the lines of the program to the Mach, paginated as
+// program output is.
+func (p *Interpreter) List() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			var ok bool
+			if err, ok = e.(error); !ok {
+				panic(e)
+			}
+		}
+	}()
+
+	p.rows = 0
+	for _, s := range p.Lines {
+		p.page(fmt.Sprintln(s))
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package interp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/parse"
+)
+
+// errTrap is the state of ON ERROR handling.
+type errTrap struct {
+	// set is true while ON ERROR GOTO is in effect, and handler is then
+	// the index of the statement to jump to.
+	set     bool
+	handler int
+
+	// active is true from an error being trapped until RESUME. err is
+	// the last error trapped, raised by the statement at index pc.
+	active bool
+	err    error
+	pc     int
+	line   int64
+}
+
+func init() {
+	RegisterBuiltin(&Builtin{
+		Name:   "ERR",
+		Syntax: "ERR",
+		Doc:    "the code of the last error trapped by ON ERROR: 11 for division by zero, 13 for type mismatch and 1 for any other",
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return Int(errCode(p.onErr.err)), nil
+		},
+	})
+	RegisterBuiltin(&Builtin{
+		Name:   "ERL",
+		Syntax: "ERL",
+		Doc:    "the line number of the statement that raised the last error trapped by ON ERROR",
+		Result: IntKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			return Int(p.onErr.line), nil
+		},
+	})
+	RegisterBuiltin(&Builtin{
+		Name:   "ERR$",
+		Syntax: "ERR$",
+		Doc:    "the message of the last error trapped by ON ERROR",
+		Result: StringKind,
+		Func: func(p *Interpreter, args []Value) (Value, error) {
+			err := p.onErr.err
+			if err == nil {
+				return String(""), nil
+			}
+			var aerr *ast.Error
+			if errors.As(err, &aerr) {
+				err = aerr.Err
+			}
+			return String(err.Error()), nil
+		},
+	})
+}
+
+func errCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrTypeMismatch):
+		return 13
+	case errors.Is(err, ErrDivisionByZero):
+		return 11
+	}
+	return 1
+}
+
+func (p *Interpreter) onError(s *ast.OnErrorStmt) {
+	if s.Target.Name == "" && s.Location.Value == 0 {
+		p.onErr.set = false
+		return
+	}
+	p.onErr.handler = p.target("on error", s.Label.Pos, s.Location, s.Target)
+	p.onErr.set = true
+}
+
+func (p *Interpreter) resume(s *ast.ResumeStmt) {
+	if !p.onErr.active {
+		p.errf(s.Resume.Pos, "resume without error")
+	}
+	switch {
+	case s.Next != nil:
+		p.PC = p.onErr.pc + 1
+	case s.Location != nil:
+		p.PC = p.target("resume", s.Label.Pos, *s.Location, s.Target)
+	case s.Target.Name != "":
+		p.PC = p.target("resume", s.Label.Pos, ast.Number{}, s.Target)
+	default:
+		p.PC = p.onErr.pc
+	}
+	p.onErr.active = false
+}
+
+// trap passes err, raised by the statement at index pc, to the handler
+// set by ON ERROR GOTO, unless there is none or it is still handling an
+// error, in which case err is returned. Frames pushed by the statement,
+// such as those of the FUNCTIONs it called, are unwound first. Parse
+// errors, limits and cancellation cannot be trapped.
+func (p *Interpreter) trap(err error, pc, depth int) error {
+	var perr *parse.Error
+	switch {
+	case !p.onErr.set, p.onErr.active, p.Halt:
+		return err
+	case errors.As(err, &perr), errors.Is(err, ErrLimit):
+		return err
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	}
+
+	p.unwind(pc, depth)
+	p.onErr.active = true
+	p.onErr.err = err
+	p.onErr.pc = pc
+	p.onErr.line = p.Lines[pc].Line()
+	p.PC = p.onErr.handler
+	return nil
+}
@@ -0,0 +1,64 @@
+package interp
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Option configures an Interpreter made by NewInterpreter. Each sets one
+// of the exported fields of the interpreter, which hosts can also set
+// directly; fields no Option sets keep their defaults.
+type Option func(p *Interpreter)
+
+// WithMaxSteps sets MaxSteps, the number of statements programs may
+// execute.
+func WithMaxSteps(n int64) Option {
+	return func(p *Interpreter) { p.MaxSteps = n }
+}
+
+// WithMaxDepth sets MaxDepth, how deeply GOSUBs and calls may nest.
+func WithMaxDepth(n int) Option {
+	return func(p *Interpreter) { p.MaxDepth = n }
+}
+
+// WithTimeout sets Timeout, the time each Run or Continue may take.
+func WithTimeout(d time.Duration) Option {
+	return func(p *Interpreter) { p.Timeout = d }
+}
+
+// WithDialect sets the settings that d determines, such as PageLength.
+func WithDialect(d *Dialect) Option {
+	return func(p *Interpreter) { p.PageLength = d.PageLength }
+}
+
+// WithStrictVars sets StrictVars, whether reading a variable never
+// assigned is an error.
+func WithStrictVars(strict bool) Option {
+	return func(p *Interpreter) { p.StrictVars = strict }
+}
+
+// WithStrictEnd sets StrictEnd, whether ending with a loop open is an
+// error.
+func WithStrictEnd(strict bool) Option {
+	return func(p *Interpreter) { p.StrictEnd = strict }
+}
+
+// WithFiles sets Files, the file system programs may open files in.
+func WithFiles(fsys FileSystem) Option {
+	return func(p *Interpreter) { p.Files = fsys }
+}
+
+// WithTracer sets Tracer, which is told of each statement executed.
+func WithTracer(t Tracer) Option {
+	return func(p *Interpreter) { p.Tracer = t }
+}
+
+// WithClock sets Clock, the source of time for programs.
+func WithClock(c Clock) Option {
+	return func(p *Interpreter) { p.Clock = c }
+}
+
+// WithRand sets Rand, the source of the numbers RND returns.
+func WithRand(r *rand.Rand) Option {
+	return func(p *Interpreter) { p.Rand = r }
+}
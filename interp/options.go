@@ -0,0 +1,230 @@
+package interp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/lex"
+	"github.com/qeedquan/go-ubasic/parse"
+)
+
+// Dialect describes a flavor of BASIC the interpreter can emulate.
+// PageLength is the number of lines on the screen of the machines the
+// dialect comes from, used when output is paginated.
+type Dialect struct {
+	Name       string
+	Doc        string
+	PageLength int
+}
+
+var defaultDialect = &Dialect{
+	Name:       "ubasic",
+	Doc:        "the default dialect, based on Adam Dunkels' uBASIC",
+	PageLength: 24,
+}
+
+var dialects = map[string]*Dialect{
+	"ubasic": defaultDialect,
+}
+
+// LookupDialect returns the dialect with the given name.
+func LookupDialect(name string) (*Dialect, bool) {
+	d, ok := dialects[strings.ToLower(name)]
+	return d, ok
+}
+
+// Dialects returns the names of all known dialects in sorted order.
+func Dialects() []string {
+	var names []string
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options are per-program settings. They can be given in the program
+// itself with directives of the form
+//
+//	REM @option dialect=ubasic maxsteps=100000 autonumber=true intwidth=16
+//	REM @option truevalue=-1 exponent=true
+//
+// so that a corpus of programs can each carry their own configuration.
+type Options struct {
+	Dialect    string
+	MaxSteps   int64
+	AutoNumber bool
+	PageLength int
+	IntWidth   int
+	TrueValue  int64
+	Exponent   bool
+}
+
+// pageLength returns the page length set by the options, or else that of
+// their dialect, or 0 if neither is known.
+func (o *Options) pageLength() int {
+	if o.PageLength > 0 {
+		return o.PageLength
+	}
+	if d, ok := LookupDialect(o.Dialect); ok {
+		return d.PageLength
+	}
+	return 0
+}
+
+// Set sets the option named key from its textual value.
+func (o *Options) Set(key, value string) error {
+	switch strings.ToLower(key) {
+	case "dialect":
+		d, ok := LookupDialect(value)
+		if !ok {
+			return fmt.Errorf("unknown dialect %q", value)
+		}
+		o.Dialect = d.Name
+	case "maxsteps":
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid maxsteps %q", value)
+		}
+		o.MaxSteps = n
+	case "autonumber":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid autonumber %q", value)
+		}
+		o.AutoNumber = b
+	case "pagelength":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 2 {
+			return fmt.Errorf("invalid pagelength %q", value)
+		}
+		o.PageLength = n
+	case "intwidth":
+		switch value {
+		case "8", "16", "32", "64":
+			o.IntWidth, _ = strconv.Atoi(value)
+		default:
+			return fmt.Errorf("invalid intwidth %q, must be 8, 16, 32 or 64", value)
+		}
+	case "exponent":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid exponent %q", value)
+		}
+		o.Exponent = b
+	case "truevalue":
+		switch value {
+		case "1", "-1":
+			o.TrueValue, _ = strconv.ParseInt(value, 10, 64)
+		default:
+			return fmt.Errorf("invalid truevalue %q, must be 1 or -1", value)
+		}
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	return nil
+}
+
+// Parse sets the options given in s as space separated key=value pairs.
+func (o *Options) Parse(s string) error {
+	for _, arg := range strings.Fields(s) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("option %q is not of the form key=value", arg)
+		}
+		if err := o.Set(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// directive splits a comment into a directive name and its arguments if
+// the comment is of the form "REM @name args" or "' @name args".
+func directive(comment string) (name, args string, ok bool) {
+	text := strings.TrimSpace(comment)
+	switch {
+	case strings.HasPrefix(text, "'"):
+		text = text[1:]
+	case len(text) >= 3 && strings.EqualFold(text[:3], "rem"):
+		text = text[3:]
+	default:
+		return
+	}
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "@") {
+		return
+	}
+	text = text[1:]
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		return strings.ToLower(text[:i]), strings.TrimSpace(text[i:]), true
+	}
+	return strings.ToLower(text), "", true
+}
+
+// scanDirectives collects the options set by directives in src, and the
+// program metadata given by any other directives, such as
+//
+//	REM @title Hunt the Wumpus
+//	REM @author Gregory Yob
+//
+// The text of a metadata directive given more than once is joined with
+// newlines. It runs before the program is parsed since options may affect
+// how it is parsed.
+func scanDirectives(name string, src []byte) (Options, map[string]string, error) {
+	var opts Options
+	meta := make(map[string]string)
+	var lexer lex.Tokenizer
+	lexer.Init(lex.Config{ScanComments: true}, name, src)
+	for {
+		pos, tok, lit := lexer.Next()
+		if tok == lex.EOF {
+			break
+		}
+		if tok != lex.REM {
+			continue
+		}
+
+		dir, args, ok := directive(lit)
+		switch {
+		case !ok:
+		case dir == "option":
+			if err := opts.Parse(args); err != nil {
+				return opts, meta, &parse.Error{Pos: pos, Err: err}
+			}
+		case meta[dir] != "":
+			meta[dir] += "\n" + args
+		default:
+			meta[dir] = args
+		}
+	}
+	return opts, meta, nil
+}
+
+// readHeader reads the blank lines and comments at the start of a
+// streamed program, where its directives are, and the line after them.
+func readHeader(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		b.WriteString(line)
+		if err == io.EOF {
+			return b.String(), nil
+		}
+		if err != nil {
+			return b.String(), err
+		}
+		text := strings.TrimLeft(strings.TrimSpace(line), "0123456789")
+		text = strings.TrimSpace(text)
+		switch {
+		case text == "", strings.HasPrefix(text, "'"):
+		case len(text) >= 3 && strings.EqualFold(text[:3], "rem") && (len(text) == 3 || text[3] == ' ' || text[3] == '\t'):
+		default:
+			return b.String(), nil
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// Size of the plots drawn by PLOT, in characters.
+const (
+	plotWidth  = 60
+	plotHeight = 16
+)
+
+func (p *Interpreter) plot(s *ast.PlotStmt) {
+	x := p.plotData(s.X)
+	if s.Y == nil {
+		p.bars(x)
+		return
+	}
+	y := p.plotData(*s.Y)
+	if len(x) != len(y) {
+		p.errf(s.Y.Pos, "plot: %v and %v have different lengths", s.X.Name, s.Y.Name)
+	}
+	p.scatter(x, y)
+}
+
+// plotData returns the elements of the numeric array named by v.
+func (p *Interpreter) plotData(v ast.Variable) []float64 {
+	a, ok := p.Vars[v.Name].(*Array)
+	if !ok {
+		p.errf(v.Pos, "plot: %v is not an array", v.Name)
+	}
+	data := make([]float64, len(a.Elems))
+	for i, e := range a.Elems {
+		f, err := AsFloat(e)
+		if err != nil {
+			p.errf(v.Pos, "plot: %w", err)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			p.errf(v.Pos, "plot: %v(%d) is %v", v.Name, i, f)
+		}
+		data[i] = f
+	}
+	return data
+}
+
+// bars draws one row per value with a bar proportional to its magnitude.
+func (p *Interpreter) bars(data []float64) {
+	max := 0.0
+	for _, v := range data {
+		max = math.Max(max, math.Abs(v))
+	}
+	w := len(fmt.Sprint(len(data) - 1))
+	for i, v := range data {
+		n := 0
+		if max > 0 {
+			n = int(math.Round(math.Abs(v) / max * plotWidth))
+		}
+		p.write(fmt.Sprintf("%*d |%s %v\n", w, i, strings.Repeat("#", n), Float(v)))
+	}
+}
+
+// scatter draws a point for each pair of values on a grid spanning their
+// range, with the y axis on the left and the x axis at the bottom.
+func (p *Interpreter) scatter(x, y []float64) {
+	grid := make([][]byte, plotHeight)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", plotWidth))
+	}
+	xmin, xmax := span(x)
+	ymin, ymax := span(y)
+	for i := range x {
+		c := scale(x[i], xmin, xmax, plotWidth)
+		r := plotHeight - 1 - scale(y[i], ymin, ymax, plotHeight)
+		grid[r][c] = '*'
+	}
+
+	labels := []string{fmt.Sprint(Float(ymax)), fmt.Sprint(Float(ymin))}
+	w := len(labels[0])
+	if len(labels[1]) > w {
+		w = len(labels[1])
+	}
+	for i, row := range grid {
+		label := ""
+		switch i {
+		case 0:
+			label = labels[0]
+		case plotHeight - 1:
+			label = labels[1]
+		}
+		p.write(fmt.Sprintf("%*s |%s\n", w, label, bytes.TrimRight(row, " ")))
+	}
+	p.write(fmt.Sprintf("%*s +%s\n", w, "", strings.Repeat("-", plotWidth)))
+	xlabels := fmt.Sprint(Float(xmin))
+	xmaxl := fmt.Sprint(Float(xmax))
+	gap := plotWidth - len(xlabels) - len(xmaxl)
+	if gap < 1 {
+		gap = 1
+	}
+	p.write(fmt.Sprintf("%*s  %s%s%s\n", w, "", xlabels, strings.Repeat(" ", gap), xmaxl))
+}
+
+func span(data []float64) (min, max float64) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	min, max = data[0], data[0]
+	for _, v := range data {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return
+}
+
+// scale maps v in [min, max] to a cell in [0, n).
+func scale(v, min, max float64, n int) int {
+	if max == min {
+		return n / 2
+	}
+	i := int(math.Round((v - min) / (max - min) * float64(n-1)))
+	if i < 0 {
+		i = 0
+	} else if i >= n {
+		i = n - 1
+	}
+	return i
+}
@@ -0,0 +1,112 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// Profiler is a Tracer that counts the statements executed on each line
+// of a program and the time spent executing them, to find where a
+// program spends its time. The time of a GOSUB or CALL is that of the
+// jump, not of the subroutine, whose lines are counted separately. The
+// time of a statement calling a FUNCTION includes that of the FUNCTION.
+type Profiler struct {
+	lines  map[int64]*LineProfile
+	starts []time.Time
+}
+
+// LineProfile is what a Profiler measured of one line.
+type LineProfile struct {
+	Line  int64
+	Count int64
+	Time  time.Duration
+}
+
+// Profile is a report of the lines executed, the most time consuming
+// first, and the total of their counts and times.
+type Profile struct {
+	Lines []LineProfile
+	Count int64
+	Time  time.Duration
+}
+
+// NewProfiler returns a Profiler that has measured nothing yet. Set it as
+// the Tracer of an interpreter to profile the programs it runs.
+func NewProfiler() *Profiler {
+	return &Profiler{lines: make(map[int64]*LineProfile)}
+}
+
+func (pr *Profiler) Before(s ast.Stmt) {
+	pr.starts = append(pr.starts, time.Now())
+}
+
+func (pr *Profiler) After(s ast.Stmt, err error) {
+	n := len(pr.starts) - 1
+	d := time.Since(pr.starts[n])
+	pr.starts = pr.starts[:n]
+	l := pr.lines[s.Line()]
+	if l == nil {
+		l = &LineProfile{Line: s.Line()}
+		pr.lines[s.Line()] = l
+	}
+	l.Count++
+	l.Time += d
+}
+
+// Reset discards what was measured so far.
+func (pr *Profiler) Reset() {
+	pr.lines = make(map[int64]*LineProfile)
+}
+
+// Report returns the profile measured so far. Lines taking the same time
+// are in order of line number.
+func (pr *Profiler) Report() *Profile {
+	r := &Profile{}
+	for _, l := range pr.lines {
+		r.Lines = append(r.Lines, *l)
+		r.Count += l.Count
+		r.Time += l.Time
+	}
+	sort.Slice(r.Lines, func(i, j int) bool {
+		a, b := r.Lines[i], r.Lines[j]
+		if a.Time != b.Time {
+			return a.Time > b.Time
+		}
+		return a.Line < b.Line
+	})
+	return r
+}
+
+// WriteTo writes the profile as a table with the share of the total time
+// each line took.
+func (r *Profile) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "LINE\tCOUNT\tTIME\t%\t")
+	for _, l := range r.Lines {
+		share := 0.0
+		if r.Time > 0 {
+			share = 100 * float64(l.Time) / float64(r.Time)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%v\t%.1f\t\n", l.Line, l.Count, l.Time, share)
+	}
+	fmt.Fprintf(tw, "total\t%d\t%v\t\t\n", r.Count, r.Time)
+	err := tw.Flush()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,294 @@
+package interp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// stateVersion is the version of the format written by SaveState.
+const stateVersion = 1
+
+// state is the JSON form of the state of an interpreter. Arrays are kept
+// in a table so that an array shared by a SUB parameter and the caller's
+// variable is still shared when restored.
+type state struct {
+	Version int                   `json:"version"`
+	Program uint64                `json:"program"`
+	Halt    bool                  `json:"halt,omitempty"`
+	Reason  HaltReason            `json:"reason,omitempty"`
+	PC      int                   `json:"pc"`
+	Steps   int64                 `json:"steps"`
+	Trace   bool                  `json:"trace,omitempty"`
+	Column  int                   `json:"column"`
+	Elapsed time.Duration         `json:"elapsed"`
+	Vars    map[string]stateValue `json:"vars"`
+	Arrays  []stateArray          `json:"arrays,omitempty"`
+	Fors    []stateFor            `json:"fors,omitempty"`
+	Whiles  []WhileStack          `json:"whiles,omitempty"`
+	Subs    []stateFrame          `json:"subs,omitempty"`
+	OnError *int                  `json:"onerror,omitempty"`
+}
+
+// stateValue is a Value. Floats are written as text so that infinities
+// and NaNs survive, and arrays as their index in the array table.
+type stateValue struct {
+	Kind  Kind   `json:"kind"`
+	Int   int64  `json:"int,omitempty"`
+	Text  string `json:"text,omitempty"`
+	Array int    `json:"array,omitempty"`
+}
+
+type stateArray struct {
+	Dims  []int        `json:"dims"`
+	Elems []stateValue `json:"elems"`
+}
+
+type stateFor struct {
+	Block int        `json:"block"`
+	Var   string     `json:"var"`
+	To    stateValue `json:"to"`
+}
+
+// stateFrame is a Frame. A variable saved as absent in the caller is
+// saved as null.
+type stateFrame struct {
+	Return int                    `json:"return"`
+	Saved  map[string]*stateValue `json:"saved,omitempty"`
+	Sub    string                 `json:"sub,omitempty"`
+	Func   bool                   `json:"func,omitempty"`
+}
+
+// SaveState returns a snapshot of the execution of the program loaded:
+// where it is, its variables, and its open loops, subroutine calls and
+// error handler, so that it can be resumed with LoadState by another
+// interpreter, even in another process, that has the same program
+// loaded. The state of the Mach, such as its memory, is not included.
+// Programs cannot be saved while they have files open, host calls
+// pending or an error being handled, or when run as a stream.
+func (p *Interpreter) SaveState() ([]byte, error) {
+	switch {
+	case p.stream != nil:
+		return nil, errors.New("save state: programs run as a stream cannot be saved")
+	case len(p.files) > 0:
+		return nil, errors.New("save state: the program has files open")
+	case len(p.pending) > 0:
+		return nil, errors.New("save state: the program has host calls pending")
+	case p.onErr.active:
+		return nil, errors.New("save state: the program is handling an error")
+	}
+
+	s := &state{
+		Version: stateVersion,
+		Program: p.fingerprint(),
+		Halt:    p.Halt,
+		Reason:  p.reason,
+		PC:      p.PC,
+		Steps:   p.Steps,
+		Trace:   p.Trace,
+		Column:  p.col,
+		Elapsed: p.now().Sub(p.start),
+		Vars:    make(map[string]stateValue),
+		Whiles:  p.Whiles,
+	}
+	arrays := make(map[*Array]int)
+	for name, v := range p.Vars {
+		s.Vars[name] = s.value(v, arrays)
+	}
+	for _, f := range p.Fors {
+		s.Fors = append(s.Fors, stateFor{Block: f.Block, Var: f.Var, To: s.value(f.To, arrays)})
+	}
+	for _, f := range p.Subs {
+		sf := stateFrame{Return: f.Return, Sub: f.Sub, Func: f.Func}
+		if f.Saved != nil {
+			sf.Saved = make(map[string]*stateValue)
+			for name, v := range f.Saved {
+				if v == nil {
+					sf.Saved[name] = nil
+					continue
+				}
+				sv := s.value(v, arrays)
+				sf.Saved[name] = &sv
+			}
+		}
+		s.Subs = append(s.Subs, sf)
+	}
+	if p.onErr.set {
+		h := p.onErr.handler
+		s.OnError = &h
+	}
+	return json.Marshal(s)
+}
+
+// value converts v, adding it to the array table if it is a new array.
+func (s *state) value(v Value, arrays map[*Array]int) stateValue {
+	switch v := v.(type) {
+	case Int:
+		return stateValue{Kind: IntKind, Int: int64(v)}
+	case Float:
+		return stateValue{Kind: FloatKind, Text: strconv.FormatFloat(float64(v), 'g', -1, 64)}
+	case String:
+		return stateValue{Kind: StringKind, Text: string(v)}
+	case *Array:
+		i, ok := arrays[v]
+		if !ok {
+			i = len(s.Arrays)
+			arrays[v] = i
+			s.Arrays = append(s.Arrays, stateArray{Dims: v.Dims})
+			elems := make([]stateValue, len(v.Elems))
+			for j, e := range v.Elems {
+				elems[j] = s.value(e, arrays)
+			}
+			s.Arrays[i].Elems = elems
+		}
+		return stateValue{Kind: ArrayKind, Array: i}
+	}
+	panic(fmt.Sprintf("interp: cannot save value of type %T", v))
+}
+
+// LoadState resumes the execution saved by SaveState. The program it was
+// saved from must be loaded, as by Load, and the state of the Mach
+// restored by the host. Host settings, such as MaxSteps, are unchanged.
+func (p *Interpreter) LoadState(data []byte) error {
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	if s.Version != stateVersion {
+		return fmt.Errorf("load state: unsupported version %d", s.Version)
+	}
+	if s.Program != p.fingerprint() {
+		return errors.New("load state: saved from a different program")
+	}
+	if s.PC < 0 || s.PC > len(p.Lines) {
+		return fmt.Errorf("load state: pc %d out of range", s.PC)
+	}
+	// The loops, returns and handler saved index the program's lines, as
+	// the PC does, and are checked so that a damaged state cannot make
+	// the program run off them. A FOR's block is the line after it.
+	for _, f := range s.Fors {
+		if f.Block < 1 || f.Block > len(p.Lines) {
+			return fmt.Errorf("load state: for %v at %d out of range", f.Var, f.Block)
+		}
+	}
+	for _, w := range s.Whiles {
+		if w.Block < 0 || w.Block >= len(p.Lines) {
+			return fmt.Errorf("load state: while at %d out of range", w.Block)
+		}
+	}
+	for _, f := range s.Subs {
+		if f.Return < 0 || f.Return > len(p.Lines) {
+			return fmt.Errorf("load state: return to %d out of range", f.Return)
+		}
+	}
+	if s.OnError != nil && (*s.OnError < 0 || *s.OnError >= len(p.Lines)) {
+		return fmt.Errorf("load state: error handler %d out of range", *s.OnError)
+	}
+
+	arrays := make([]*Array, len(s.Arrays))
+	for i, a := range s.Arrays {
+		arrays[i] = &Array{Dims: a.Dims}
+	}
+	var err error
+	value := func(sv stateValue) Value {
+		v, verr := sv.value(arrays)
+		if verr != nil && err == nil {
+			err = verr
+		}
+		return v
+	}
+	for i, a := range s.Arrays {
+		n := 1
+		for _, d := range a.Dims {
+			n *= d
+		}
+		if len(a.Dims) == 0 || n != len(a.Elems) {
+			return fmt.Errorf("load state: array %d has %d elements for dimensions %v", i, len(a.Elems), a.Dims)
+		}
+		arrays[i].Elems = make([]Value, len(a.Elems))
+		for j, e := range a.Elems {
+			arrays[i].Elems[j] = value(e)
+		}
+	}
+
+	vars := make(map[string]Value)
+	for name, v := range s.Vars {
+		vars[name] = value(v)
+	}
+	var fors []ForStack
+	for _, f := range s.Fors {
+		fors = append(fors, ForStack{Block: f.Block, Var: f.Var, To: value(f.To)})
+	}
+	var subs []Frame
+	for _, f := range s.Subs {
+		fr := Frame{Return: f.Return, Sub: f.Sub, Func: f.Func}
+		if f.Saved != nil {
+			fr.Saved = make(map[string]Value)
+			for name, v := range f.Saved {
+				if v == nil {
+					fr.Saved[name] = nil
+				} else {
+					fr.Saved[name] = value(*v)
+				}
+			}
+		}
+		subs = append(subs, fr)
+	}
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+
+	p.Reset()
+	p.Halt = s.Halt
+	p.reason = s.Reason
+	p.PC = s.PC
+	p.Steps = s.Steps
+	p.Trace = s.Trace
+	p.col = s.Column
+	p.start = p.now().Add(-s.Elapsed)
+	p.Vars = vars
+	p.Fors = fors
+	p.Whiles = s.Whiles
+	p.Subs = subs
+	if s.OnError != nil {
+		p.onErr = errTrap{set: true, handler: *s.OnError}
+	}
+	return nil
+}
+
+func (sv stateValue) value(arrays []*Array) (Value, error) {
+	switch sv.Kind {
+	case IntKind:
+		return Int(sv.Int), nil
+	case FloatKind:
+		f, err := strconv.ParseFloat(sv.Text, 64)
+		if err != nil {
+			return Int(0), fmt.Errorf("invalid float %q", sv.Text)
+		}
+		return Float(f), nil
+	case StringKind:
+		return String(sv.Text), nil
+	case ArrayKind:
+		if sv.Array < 0 || sv.Array >= len(arrays) {
+			return Int(0), fmt.Errorf("array %d out of range", sv.Array)
+		}
+		return arrays[sv.Array], nil
+	}
+	return Int(0), fmt.Errorf("invalid kind %d", sv.Kind)
+}
+
+// fingerprint identifies the program loaded by the kinds, positions and
+// line numbers of its statements, so that a state is not restored into a
+// program whose statements it does not describe. The file name is left
+// out, as the program may be loaded from elsewhere when it is resumed.
+func (p *Interpreter) fingerprint() uint64 {
+	h := fnv.New64a()
+	for _, s := range p.Lines {
+		pos := s.Pos()
+		fmt.Fprintf(h, "%d %T %d:%d\n", s.Line(), s, pos.Line, pos.Column)
+	}
+	return h.Sum64()
+}
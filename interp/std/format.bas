@@ -0,0 +1,38 @@
+rem std:format - formatting integers for display
+
+rem commas$ writes n with commas between groups of three digits.
+function commas$(n)
+local s$, r$
+s$ = str$(n)
+if n < 0 then s$ = right$(s$, len(s$) - 1)
+r$ = ""
+while len(s$) > 3
+r$ = "," + right$(s$, 3) + r$
+s$ = left$(s$, len(s$) - 3)
+wend
+r$ = s$ + r$
+if n < 0 then r$ = "-" + r$
+return r$
+end function
+
+rem zeropad$ writes n with leading zeros to w digits.
+function zeropad$(n, w)
+local s$
+s$ = str$(n)
+if n < 0 then s$ = right$(s$, len(s$) - 1)
+while len(s$) < w
+s$ = "0" + s$
+wend
+if n < 0 then s$ = "-" + s$
+return s$
+end function
+
+rem fixed$ writes n, a number of hundredths when d is 2 and so on, with
+rem d digits after the decimal point, so fixed$(1234, 2) is "12.34".
+function fixed$(n, d)
+local s$
+if d <= 0 then return str$(n)
+if n < 0 then return "-" + fixed$(-n, d)
+s$ = zeropad$(n, d + 1)
+return left$(s$, len(s$) - d) + "." + right$(s$, d)
+end function
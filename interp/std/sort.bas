@@ -0,0 +1,26 @@
+rem std:sort - sorting arrays in place
+
+rem sort sorts the first n elements of the array a, numbers or strings,
+rem in ascending order.
+sub sort(a, n)
+local i, j, t
+for i = 1 to n - 1
+t = a(i)
+j = i - 1
+while j >= 0 and a(j) > t
+a(j + 1) = a(j)
+j = j - 1
+wend
+a(j + 1) = t
+next i
+end sub
+
+rem reverse reverses the order of the first n elements of the array a.
+sub reverse(a, n)
+local i, t
+for i = 0 to n / 2 - 1
+t = a(i)
+a(i) = a(n - 1 - i)
+a(n - 1 - i) = t
+next i
+end sub
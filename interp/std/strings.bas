@@ -0,0 +1,43 @@
+rem std:strings - padding, trimming and repeating strings
+
+function repeat$(s$, n)
+local r$
+r$ = ""
+while n > 0
+r$ = r$ + s$
+n = n - 1
+wend
+return r$
+end function
+
+rem lpad$ pads s$ with spaces on the left to n characters.
+function lpad$(s$, n)
+if len(s$) >= n then return s$
+return repeat$(" ", n - len(s$)) + s$
+end function
+
+rem rpad$ pads s$ with spaces on the right to n characters.
+function rpad$(s$, n)
+if len(s$) >= n then return s$
+return s$ + repeat$(" ", n - len(s$))
+end function
+
+rem center$ pads s$ with spaces on both sides to n characters.
+function center$(s$, n)
+if len(s$) >= n then return s$
+return rpad$(lpad$(s$, len(s$) + (n - len(s$)) / 2), n)
+end function
+
+rem trim$ removes the spaces at both ends of s$.
+function trim$(s$)
+local i, j
+i = 1
+j = len(s$)
+while i <= j and mid$(s$, i, 1) = " "
+i = i + 1
+wend
+while j >= i and mid$(s$, j, 1) = " "
+j = j - 1
+wend
+return mid$(s$, i, j - i + 1)
+end function
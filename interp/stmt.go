@@ -0,0 +1,102 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Statement documents a statement understood by the interpreter.
+type Statement struct {
+	Name   string
+	Syntax string
+	Doc    string
+}
+
+var statements = map[string]*Statement{}
+
+func init() {
+	for _, s := range []*Statement{
+		{"PRINT", "PRINT [#n,] item [, | ;] ...", "write values, TAB(n) or SPC(n) and end the line; a comma moves to the next print zone, a semicolon nothing, and either at the end keeps the line open"},
+		{"GET", "GET var", "read a key without waiting; var$ gets the key or \"\", var its code or 0"},
+		{"CALL", "CALL name[(args)]", "call a procedure bound by the host, a SUB, or a FUNCTION discarding its value"},
+		{"SUB", "SUB name[(param [, param] ...)]", "declare a procedure up to END SUB, whose parameters are local to each CALL"},
+		{"WAIT", "WAIT [name [, var]]", "wait for asynchronous calls to name, or all of them, storing the last result in var"},
+		{"DIM", "DIM a(n [, m] ...) [, ...]", "declare arrays with indexes from 0 to n in each dimension; a$ arrays hold strings"},
+		{"PLOT", "PLOT a [, b]", "plot the numbers in array a as bars, or a against b as a scatter plot"},
+		{"TRON", "TRON", "trace execution, writing the number of each line run as [10][20]"},
+		{"TROFF", "TROFF", "stop tracing execution"},
+		{"SYS", "SYS addr [, arg] ...", "call the host routine bound to addr"},
+		{"CLEAR", "CLEAR", "remove all variables and forget open loops and GOSUBs without restarting the program"},
+		{"CLS", "CLS", "clear the screen and move the cursor home"},
+		{"LOCATE", "LOCATE row, col", "move the cursor to row and col, counting from 1"},
+		{"COLOR", "COLOR fg [, bg]", "set the text colors, 0 to 15"},
+		{"INPUT", "INPUT [#n, | \"prompt\";] var [, var] ...", "read a line of comma separated values into the variables, strings into those ending in $, from the screen or the file open on channel n"},
+		{"OPEN", "OPEN name FOR INPUT | OUTPUT | APPEND AS #n", "open the file name for reading, writing or appending on channel n, from 1 to 255"},
+		{"CHAIN", "CHAIN name [, [line] [, ALL]]", "replace the program with the one in the file name and run it from the start or from line, clearing variables unless ALL is given; files stay open"},
+		{"CLOSE", "CLOSE [#n [, #n] ...]", "close the files open on the channels given, or all open files"},
+		{"LET", "[LET] var = expr", "assign the value of expr to var"},
+		{"CONST", "CONST name = expr", "declare a constant, replaced by its value wherever it is used"},
+		{"LOCAL", "LOCAL var [, var] ...", "make variables local to the current GOSUB, SUB or FUNCTION, restoring them when it returns"},
+		{"IF", "IF cond THEN stmt [ELSE stmt]", "run stmt if cond is true; the multi-line form takes ELSEIF and ELSE lines"},
+		{"GOTO", "GOTO line | label | expr", "continue at line, at the line declared as label: on its own, or at the line numbered by expr, which may be a string"},
+		{"GOSUB", "GOSUB line | label | expr", "call the subroutine at line, at label, or at the line numbered by expr, as for GOTO"},
+		{"POP", "POP", "discard the return address of the current GOSUB, so that it can be left with GOTO"},
+		{"RETURN", "RETURN [expr]", "return from the current GOSUB or SUB, or from a FUNCTION with the value of expr"},
+		{"FUNCTION", "FUNCTION name[(param [, param] ...)]", "declare a function up to END FUNCTION, called from expressions as name(args)"},
+		{"INCLUDE", "INCLUDE \"std:name\" | INCLUDE \"file\"", "add the SUBs and FUNCTIONs of a standard library, such as std:strings, std:format or std:sort, or of a file relative to the including one, to the program"},
+		{"RUN", "RUN [line | label]", "clear all variables and run the program again from the start or from line or label"},
+		{"ON", "ON ERROR GOTO line | label", "jump to line or label when a runtime error occurs; ON ERROR GOTO 0 turns trapping off"},
+		{"RESUME", "RESUME [NEXT | line | label]", "end an error handler, retrying the statement that failed, continuing after it, or at line or label"},
+		{"FOR", "FOR var = expr TO expr", "loop over a range of values, up to the matching NEXT"},
+		{"NEXT", "NEXT [var]", "end of the innermost FOR loop, or of the loop over var, abandoning the loops inside it"},
+		{"WHILE", "WHILE cond", "loop while cond is true, up to the matching WEND"},
+		{"WEND", "WEND", "end of a WHILE loop"},
+		{"PEEK", "PEEK addr, var", "read the machine value at addr into var; PEEK(addr) reads it in expressions"},
+		{"POKE", "POKE addr, expr", "write expr to the machine at addr"},
+		{"POKE8", "POKE8 addr, expr", "write the low byte of expr to addr"},
+		{"POKE16", "POKE16 addr, expr", "write the low 16 bits of expr to the two bytes from addr"},
+		{"POKE32", "POKE32 addr, expr", "write the low 32 bits of expr to the four bytes from addr"},
+		{"END", "END [SUB | FUNCTION]", "stop the program, abandoning open loops and pending GOSUBs; END SUB and END FUNCTION return from a SUB or FUNCTION"},
+		{"REM", "REM text | ' text", "comment to the end of the line, which ' may also start after a statement; REM @option key=value sets program options"},
+	} {
+		statements[s.Name] = s
+	}
+}
+
+// LookupStatement returns the statement with the given keyword.
+func LookupStatement(name string) (*Statement, bool) {
+	s, ok := statements[strings.ToUpper(name)]
+	return s, ok
+}
+
+// Statements returns the keywords of all statements in sorted order.
+func Statements() []string {
+	var names []string
+	for name := range statements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// help writes the documentation for topic, a statement keyword or builtin
+// function name, or an overview of everything documented if topic is
+// empty.
+func help(w io.Writer, topic string) {
+	if topic == "" {
+		fmt.Fprintln(w, "statements:", strings.Join(Statements(), " "))
+		fmt.Fprintln(w, "functions: ", strings.Join(Builtins(), " "))
+		fmt.Fprintln(w, "commands:   p (list program) q (quit) help [name]")
+		return
+	}
+
+	if s, ok := LookupStatement(topic); ok {
+		fmt.Fprintf(w, "%s\n\t%s\n", s.Syntax, s.Doc)
+	} else if b, ok := LookupBuiltin(topic); ok {
+		fmt.Fprintf(w, "%s\n\t%s\n", b.Syntax, b.Doc)
+	} else {
+		fmt.Fprintf(w, "no help for %q\n", topic)
+	}
+}
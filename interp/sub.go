@@ -0,0 +1,174 @@
+package interp
+
+import (
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// halted is raised to abandon the statement being executed when the
+// program ends inside a FUNCTION called from it.
+type halted struct{}
+
+// enter calls the SUB or FUNCTION at index loc with the arguments of e,
+// pushing a frame in which they are bound to params. Parameters are bound
+// like locals, so the caller's variables of the same names are restored
+// when the procedure returns.
+func (p *Interpreter) enter(e *ast.CallExpr, name ast.Variable, params []ast.Variable, loc int, fn bool) {
+	if len(e.Args) != len(params) {
+		p.errk(e.Func.Pos, ErrArgumentCount, "%v takes %d arguments, got %d", name.Name, len(params), len(e.Args))
+	}
+	if p.MaxDepth > 0 && len(p.Subs) >= p.MaxDepth {
+		p.errf(e.Func.Pos, "%v: depth %w (%d)", name.Name, ErrLimit, p.MaxDepth)
+	}
+
+	args := make([]Value, len(e.Args))
+	for i, a := range e.Args {
+		args[i] = p.expr(a)
+	}
+
+	f := Frame{
+		Return: p.PC,
+		Saved:  make(map[string]Value),
+		Sub:    name.Name,
+		Func:   fn,
+	}
+	for i, v := range params {
+		f.Saved[v.Name] = p.Vars[v.Name]
+		p.setVar(v.Name, args[i])
+	}
+	p.Subs = append(p.Subs, f)
+	p.cover(p.Lines[loc])
+	p.PC = loc + 1
+}
+
+// callProc runs the procedure at index loc for a CALL statement,
+// discarding the result if it is a FUNCTION.
+func (p *Interpreter) callProc(s *ast.CallStmt, loc int) {
+	switch proc := p.Lines[loc].(type) {
+	case *ast.SubStmt:
+		p.enter(s.X, proc.Name, proc.Params, loc, false)
+	case *ast.FunctionStmt:
+		p.callFunc(s.X, loc)
+	}
+}
+
+// callFunc runs the FUNCTION at index loc to completion and returns its
+// result. The function's statements are executed here rather than by
+// Step, since the expression calling it is waiting for the result.
+func (p *Interpreter) callFunc(e *ast.CallExpr, loc int) Value {
+	fn, ok := p.Lines[loc].(*ast.FunctionStmt)
+	if !ok {
+		p.errf(e.Func.Pos, "%v is a sub, not a function", e.Func.Name)
+	}
+
+	depth := len(p.Subs)
+	p.enter(e, fn.Name, fn.Params, loc, true)
+	for len(p.Subs) > depth {
+		if p.PC >= len(p.Lines) && !p.mustMore() {
+			p.errf(fn.Function.Pos, "function %v without end function", fn.Name.Name)
+		}
+		s := p.Lines[p.PC]
+		if err := p.beginStep(s); err != nil {
+			panic(err)
+		}
+		p.PC++
+		p.nested(s)
+	}
+	if p.Halt {
+		panic(halted{})
+	}
+
+	v := p.result
+	p.result = nil
+	return v
+}
+
+// nested executes s, a statement of a FUNCTION, and tells the Tracer when
+// it is done. A failure is passed on to the statement calling the
+// FUNCTION, which fails with it.
+func (p *Interpreter) nested(s ast.Stmt) {
+	if p.Tracer == nil {
+		p.stmt(s)
+		return
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			var err error
+			if _, ok := e.(halted); !ok {
+				err = positioned(s, e)
+			}
+			p.Tracer.After(s, err)
+			panic(e)
+		}
+	}()
+	p.stmt(s)
+	p.Tracer.After(s, nil)
+}
+
+// funcReturn returns from the current FUNCTION with the value v, or the
+// zero value for its name if v is nil.
+func (p *Interpreter) funcReturn(v Value) {
+	f := p.Subs[len(p.Subs)-1]
+	if v == nil {
+		v = Int(0)
+		if strings.HasSuffix(f.Sub, "$") {
+			v = String("")
+		}
+	}
+	p.result = v
+	p.ret()
+}
+
+// skipProc skips over a SUB or FUNCTION that execution reached other
+// than by calling it.
+func (p *Interpreter) skipProc(s ast.Stmt, name ast.Variable) {
+	for i := p.PC; ; i++ {
+		if i >= len(p.Lines) && !p.mustMore() {
+			break
+		}
+		switch p.Lines[i].(type) {
+		case *ast.EndSubStmt:
+			if _, ok := s.(*ast.SubStmt); ok {
+				p.PC = i + 1
+				return
+			}
+		case *ast.EndFunctionStmt:
+			if _, ok := s.(*ast.FunctionStmt); ok {
+				p.PC = i + 1
+				return
+			}
+		}
+	}
+	if _, ok := s.(*ast.SubStmt); ok {
+		p.errf(s.Pos(), "sub %v without end sub", name.Name)
+	}
+	p.errf(s.Pos(), "function %v without end function", name.Name)
+}
+
+func (p *Interpreter) endSub(s *ast.EndSubStmt) {
+	if n := len(p.Subs); n == 0 || p.Subs[n-1].Sub == "" || p.Subs[n-1].Func {
+		p.errf(s.End.Pos, "end sub outside of call")
+	}
+	p.ret()
+}
+
+func (p *Interpreter) endFunction(s *ast.EndFunctionStmt) {
+	if n := len(p.Subs); n == 0 || !p.Subs[n-1].Func {
+		p.errf(s.End.Pos, "end function outside of call")
+	}
+	p.funcReturn(nil)
+}
+
+// locateProc is like locate for procedures.
+func (p *Interpreter) locateProc(name string) (int, bool) {
+	name = strings.ToUpper(name)
+	for {
+		if loc, found := p.Procs[name]; found {
+			return loc, true
+		}
+		if !p.mustMore() {
+			return 0, false
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package interp
+
+import (
+	"context"
+	"sync"
+)
+
+// SyncMach is a Mach that serializes the calls made to another with a
+// mutex, so that interpreters running in separate goroutines can share
+// it, as when several programs drive one memory bus. Each call is atomic,
+// including sized and error checked accesses and Flush, which are passed
+// on if the Mach has them; a machine without sized accesses is accessed
+// a byte at a time, in little endian order, with errors reported by the
+// accesses that return them. Input and terminal control are not passed on.
+type SyncMach struct {
+	mu   sync.Mutex
+	mach Mach
+}
+
+// NewSyncMach returns a SyncMach guarding mach. All accesses to mach
+// should go through it from then on.
+func NewSyncMach(mach Mach) *SyncMach {
+	return &SyncMach{mach: mach}
+}
+
+func (m *SyncMach) Write(b []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mach.Write(b)
+}
+
+func (m *SyncMach) Peek(addr int64) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mach.Peek(addr)
+}
+
+func (m *SyncMach) Poke(addr, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mach.Poke(addr, value)
+}
+
+func (m *SyncMach) PeekErr(addr int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return machPeek(m.mach, addr)
+}
+
+func (m *SyncMach) PokeErr(addr, value int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return machPoke(m.mach, addr, value)
+}
+
+func (m *SyncMach) PeekSized(addr int64, bits int) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.mach.(SizedMach); ok {
+		return s.PeekSized(addr, bits)
+	}
+	var v int64
+	for i := 0; i < bits/8; i++ {
+		v |= (m.mach.Peek(addr+int64(i)) & 0xff) << (8 * uint(i))
+	}
+	return v
+}
+
+func (m *SyncMach) PokeSized(addr int64, bits int, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.mach.(SizedMach); ok {
+		s.PokeSized(addr, bits, value)
+		return
+	}
+	for i := 0; i < bits/8; i++ {
+		m.mach.Poke(addr+int64(i), value>>(8*uint(i))&0xff)
+	}
+}
+
+func (m *SyncMach) PeekSizedErr(addr int64, bits int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return machPeekSized(m.mach, addr, bits)
+}
+
+func (m *SyncMach) PokeSizedErr(addr int64, bits int, value int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return machPokeSized(m.mach, addr, bits, value)
+}
+
+func (m *SyncMach) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.mach.(FlushMach); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Pool runs programs concurrently on one Mach, each in a goroutine of
+// its own with an interpreter of its own, and collects how each ended.
+type Pool struct {
+	ctx  context.Context
+	mach *SyncMach
+	opts []Option
+
+	wg      sync.WaitGroup
+	results []*PoolResult
+}
+
+// PoolResult is how a program run by a Pool ended: the interpreter that
+// ran it, in the state it ended in, and the error it failed with, if any.
+type PoolResult struct {
+	Prog   *Program
+	Interp *Interpreter
+	Err    error
+}
+
+// NewPool returns a Pool running programs on mach, through a SyncMach
+// unless mach is one already, with interpreters made with opts. The
+// programs stop once ctx is cancelled.
+func NewPool(ctx context.Context, mach Mach, opts ...Option) *Pool {
+	m, ok := mach.(*SyncMach)
+	if !ok {
+		m = NewSyncMach(mach)
+	}
+	return &Pool{ctx: ctx, mach: m, opts: opts}
+}
+
+// Go starts running prog.
+func (pl *Pool) Go(prog *Program) {
+	r := &PoolResult{Prog: prog, Interp: NewInterpreter(pl.mach, pl.opts...)}
+	pl.results = append(pl.results, r)
+	pl.wg.Add(1)
+	go func() {
+		defer pl.wg.Done()
+		r.Err = r.Interp.RunContext(pl.ctx, r.Prog)
+	}()
+}
+
+// Wait waits for the programs started to end and returns their results
+// in the order they were started.
+func (pl *Pool) Wait() []*PoolResult {
+	pl.wg.Wait()
+	return pl.results
+}
@@ -0,0 +1,83 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/qeedquan/go-ubasic/ast"
+)
+
+// TermMach is implemented by machines with a screen that is not driven
+// by ANSI escape sequences. Rows and columns count from 1; colors are
+// numbered 0 to 15 as on the PC, with a negative background meaning the
+// background is unchanged.
+type TermMach interface {
+	Mach
+	Clear()
+	Locate(row, col int)
+	Color(fg, bg int)
+}
+
+// ansiColors maps PC color numbers to ANSI ones; 8 to 15 are the bright
+// versions of 0 to 7.
+var ansiColors = [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+
+func (p *Interpreter) cls(s *ast.ClsStmt) {
+	if t, ok := p.Mach.(TermMach); ok {
+		t.Clear()
+	} else {
+		io.WriteString(p.Mach, "\x1b[2J\x1b[H")
+	}
+	p.col = 0
+	p.rows = 0
+}
+
+func (p *Interpreter) locate_(s *ast.LocateStmt) {
+	row, col := p.int(s.Row), p.int(s.Col)
+	if row < 1 {
+		p.errf(ast.ExprPos(s.Row), "locate: row %d out of range", row)
+	}
+	if col < 1 {
+		p.errf(ast.ExprPos(s.Col), "locate: column %d out of range", col)
+	}
+
+	if t, ok := p.Mach.(TermMach); ok {
+		t.Locate(int(row), int(col))
+	} else {
+		fmt.Fprintf(p.Mach, "\x1b[%d;%dH", row, col)
+	}
+	p.col = int(col) - 1
+}
+
+func (p *Interpreter) color(s *ast.ColorStmt) {
+	fg, bg := p.colorArg(s.Fg), -1
+	if s.Bg != nil {
+		bg = p.colorArg(s.Bg)
+	}
+
+	if t, ok := p.Mach.(TermMach); ok {
+		t.Color(fg, bg)
+		return
+	}
+	fmt.Fprintf(p.Mach, "\x1b[%dm", ansiColor(30, fg))
+	if bg >= 0 {
+		fmt.Fprintf(p.Mach, "\x1b[%dm", ansiColor(40, bg))
+	}
+}
+
+func (p *Interpreter) colorArg(e ast.Expr) int {
+	n := p.int(e)
+	if n < 0 || n > 15 {
+		p.errf(ast.ExprPos(e), "color: %d out of range", n)
+	}
+	return int(n)
+}
+
+// ansiColor returns the SGR parameter for color n, where base is 30 for
+// the foreground and 40 for the background.
+func ansiColor(base, n int) int {
+	if n >= 8 {
+		base += 60
+	}
+	return base + ansiColors[n%8]
+}
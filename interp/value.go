@@ -0,0 +1,302 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// Kind identifies the type of a Value.
+type Kind int
+
+const (
+	IntKind Kind = iota
+	FloatKind
+	StringKind
+	ArrayKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case IntKind:
+		return "int"
+	case FloatKind:
+		return "float"
+	case StringKind:
+		return "string"
+	case ArrayKind:
+		return "array"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Value is a value computed by a program. All arithmetic and comparison
+// goes through Binary and Unary, which dispatch on the kinds of their
+// operands.
+type Value interface {
+	Kind() Kind
+	String() string
+}
+
+type (
+	Int    int64
+	Float  float64
+	String string
+)
+
+// Array is a fixed size, possibly multi-dimensional array of values
+// stored in row-major order.
+type Array struct {
+	Dims  []int
+	Elems []Value
+}
+
+func (Int) Kind() Kind    { return IntKind }
+func (Float) Kind() Kind  { return FloatKind }
+func (String) Kind() Kind { return StringKind }
+func (*Array) Kind() Kind { return ArrayKind }
+
+func (v Int) String() string    { return strconv.FormatInt(int64(v), 10) }
+func (v Float) String() string  { return strconv.FormatFloat(float64(v), 'g', -1, 64) }
+func (v String) String() string { return string(v) }
+
+func (a *Array) String() string {
+	var elems []string
+	for _, v := range a.Elems {
+		elems = append(elems, v.String())
+	}
+	return "[" + strings.Join(elems, " ") + "]"
+}
+
+func truth(x bool) Int {
+	if x {
+		return 1
+	}
+	return 0
+}
+
+// AsInt returns v as an integer, truncating floats.
+func AsInt(v Value) (int64, error) {
+	switch v := v.(type) {
+	case Int:
+		return int64(v), nil
+	case Float:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("%w: expected number, got %v", ErrTypeMismatch, v.Kind())
+}
+
+// AsFloat returns v as a floating point number.
+func AsFloat(v Value) (float64, error) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v), nil
+	case Float:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("%w: expected number, got %v", ErrTypeMismatch, v.Kind())
+}
+
+// IsTrue reports whether v is a true condition, that is a non-zero
+// number.
+func IsTrue(v Value) (bool, error) {
+	switch v := v.(type) {
+	case Int:
+		return v != 0, nil
+	case Float:
+		return v != 0, nil
+	}
+	return false, fmt.Errorf("%w: expected condition, got %v", ErrTypeMismatch, v.Kind())
+}
+
+// Compare returns -1, 0 or 1 depending on whether x is less than, equal
+// to or greater than y. Numbers compare with numbers and strings with
+// strings.
+func Compare(x, y Value) (int, error) {
+	switch {
+	case x.Kind() == IntKind && y.Kind() == IntKind:
+		a, b := x.(Int), y.(Int)
+		switch {
+		case a < b:
+			return -1, nil
+		case a > b:
+			return 1, nil
+		}
+		return 0, nil
+	case isNumber(x) && isNumber(y):
+		a, _ := AsFloat(x)
+		b, _ := AsFloat(y)
+		switch {
+		case a < b:
+			return -1, nil
+		case a > b:
+			return 1, nil
+		}
+		return 0, nil
+	case x.Kind() == StringKind && y.Kind() == StringKind:
+		return strings.Compare(string(x.(String)), string(y.(String))), nil
+	}
+	return 0, fmt.Errorf("%w: cannot compare %v with %v", ErrTypeMismatch, x.Kind(), y.Kind())
+}
+
+func isNumber(v Value) bool {
+	k := v.Kind()
+	return k == IntKind || k == FloatKind
+}
+
+// Binary applies the binary operator op to x and y.
+func Binary(op lex.Token, x, y Value) (Value, error) {
+	switch op {
+	case lex.LT, lex.GT, lex.LEQ, lex.GEQ, lex.NEQ, lex.EQ:
+		c, err := Compare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case lex.LT:
+			return truth(c < 0), nil
+		case lex.GT:
+			return truth(c > 0), nil
+		case lex.LEQ:
+			return truth(c <= 0), nil
+		case lex.GEQ:
+			return truth(c >= 0), nil
+		case lex.NEQ:
+			return truth(c != 0), nil
+		default:
+			return truth(c == 0), nil
+		}
+	}
+
+	switch {
+	case x.Kind() == StringKind && y.Kind() == StringKind:
+		if op == lex.PLUS {
+			return x.(String) + y.(String), nil
+		}
+		return nil, fmt.Errorf("%w: operator %q is not defined on strings", ErrTypeMismatch, op)
+	case !isNumber(x) || !isNumber(y):
+		return nil, fmt.Errorf("%w: operator %q is not defined on %v and %v", ErrTypeMismatch, op, x.Kind(), y.Kind())
+	case x.Kind() == FloatKind || y.Kind() == FloatKind:
+		switch op {
+		case lex.PLUS, lex.MINUS, lex.ASTR, lex.SLASH, lex.POW:
+			a, _ := AsFloat(x)
+			b, _ := AsFloat(y)
+			if op == lex.SLASH && b == 0 {
+				return nil, ErrDivisionByZero
+			}
+			return floatOp(op, a, b), nil
+		}
+	}
+
+	a, _ := AsInt(x)
+	b, _ := AsInt(y)
+	return intOp(op, a, b)
+}
+
+func floatOp(op lex.Token, a, b float64) Value {
+	switch op {
+	case lex.PLUS:
+		return Float(a + b)
+	case lex.MINUS:
+		return Float(a - b)
+	case lex.ASTR:
+		return Float(a * b)
+	case lex.POW:
+		return Float(math.Pow(a, b))
+	default:
+		return Float(a / b)
+	}
+}
+
+func intOp(op lex.Token, a, b int64) (Value, error) {
+	switch op {
+	case lex.PLUS:
+		return Int(a + b), nil
+	case lex.MINUS:
+		return Int(a - b), nil
+	case lex.ASTR:
+		return Int(a * b), nil
+	case lex.SLASH, lex.BACKSLASH, lex.MOD:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if op == lex.MOD {
+			return Int(a % b), nil
+		}
+		return Int(a / b), nil
+	case lex.AND:
+		return Int(a & b), nil
+	case lex.OR:
+		return Int(a | b), nil
+	case lex.XOR:
+		return Int(a ^ b), nil
+	case lex.POW:
+		if b < 0 {
+			return Float(math.Pow(float64(a), float64(b))), nil
+		}
+		n := int64(1)
+		for ; b > 0; b >>= 1 {
+			if b&1 != 0 {
+				n *= a
+			}
+			a *= a
+		}
+		return Int(n), nil
+	case lex.SHL, lex.SHR:
+		if b < 0 {
+			return nil, fmt.Errorf("negative shift count %d", b)
+		}
+		if op == lex.SHL {
+			return Int(a << uint64(b)), nil
+		}
+		return Int(a >> uint64(b)), nil
+	}
+	return nil, fmt.Errorf("unknown binary operator %q", op)
+}
+
+// Unary applies the unary operator op to x.
+func Unary(op lex.Token, x Value) (Value, error) {
+	switch x := x.(type) {
+	case Int:
+		switch op {
+		case lex.MINUS:
+			return -x, nil
+		case lex.PLUS:
+			return x, nil
+		case lex.NOT:
+			return truth(x == 0), nil
+		case lex.TILDE:
+			return ^x, nil
+		}
+	case Float:
+		switch op {
+		case lex.MINUS:
+			return -x, nil
+		case lex.PLUS:
+			return x, nil
+		case lex.NOT:
+			return truth(x == 0), nil
+		case lex.TILDE:
+			return ^Int(x), nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: operator %q is not defined on %v", ErrTypeMismatch, op, x.Kind())
+	}
+	return nil, fmt.Errorf("unknown unary operator %q", op)
+}
+
+// Zero returns the zero value of kind k.
+func Zero(k Kind) Value {
+	switch k {
+	case FloatKind:
+		return Float(0)
+	case StringKind:
+		return String("")
+	case ArrayKind:
+		return &Array{}
+	}
+	return Int(0)
+}
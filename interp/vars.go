@@ -0,0 +1,122 @@
+package interp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/scanner"
+
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// GetVar returns the value of the variable name. Variable names are case
+// sensitive in programs, but hosts may use any case if only one variable
+// matches.
+func (p *Interpreter) GetVar(name string) (Value, bool) {
+	v, ok := p.Vars[p.varName(name)]
+	return v, ok
+}
+
+// SetVar assigns v to the variable name, found as by GetVar or created if
+// there is none. The name must be one a program could use, and v must be
+// a string if the name ends in $ and a number otherwise, or an array of
+// those.
+func (p *Interpreter) SetVar(name string, v Value) error {
+	if err := checkVarName(name); err != nil {
+		return err
+	}
+	name = p.varName(name)
+	str := strings.HasSuffix(name, "$")
+	elems := []Value{v}
+	if a, ok := v.(*Array); ok {
+		elems = a.Elems
+	}
+	for _, e := range elems {
+		if e == nil || e.Kind() == ArrayKind || (e.Kind() == StringKind) != str {
+			return fmt.Errorf("set %s: %w: cannot assign %s", name, ErrTypeMismatch, kindOf(e))
+		}
+	}
+	p.setVar(name, v)
+	return nil
+}
+
+// ListVars returns the names of the variables in sorted order.
+func (p *Interpreter) ListVars() []string {
+	var names []string
+	for name := range p.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// varName returns the name of the variable a host means by name.
+func (p *Interpreter) varName(name string) string {
+	if _, ok := p.Vars[name]; ok {
+		return name
+	}
+	match := ""
+	for n := range p.Vars {
+		if strings.EqualFold(n, name) {
+			if match != "" {
+				return name
+			}
+			match = n
+		}
+	}
+	if match == "" {
+		return name
+	}
+	return match
+}
+
+// checkVarName checks that name is a variable name and not a keyword.
+func checkVarName(name string) error {
+	var lexer lex.Tokenizer
+	lexer.Init(lex.Config{}, "", []byte(name))
+	_, tok, lit := lexer.Next()
+	if tok != lex.VARIABLE || lit != name {
+		return fmt.Errorf("%q is not a variable name", name)
+	}
+	if _, tok, _ = lexer.Next(); tok != lex.EOF {
+		return fmt.Errorf("%q is not a variable name", name)
+	}
+	return nil
+}
+
+func kindOf(v Value) string {
+	if v == nil {
+		return "nil"
+	}
+	return v.Kind().String()
+}
+
+// unassigned returns the value read at pos from the variable name, which
+// was never assigned: an error if StrictVars is set, and otherwise zero.
+func (p *Interpreter) unassigned(pos scanner.Position, name string) Value {
+	if p.StrictVars {
+		p.errk(pos, ErrUndefinedVariable, "unknown variable name %v", name)
+	}
+	if strings.HasSuffix(name, "$") {
+		return String("")
+	}
+	return Int(0)
+}
+
+// setVar assigns v to the variable name and tells the host.
+func (p *Interpreter) setVar(name string, v Value) {
+	p.Vars[name] = v
+	if p.VarChanged != nil {
+		p.VarChanged(name, v)
+	}
+	p.emit(Event{Kind: EventAssigned, Name: name, Value: v})
+}
+
+// unsetVar removes the variable name and tells the host.
+func (p *Interpreter) unsetVar(name string) {
+	delete(p.Vars, name)
+	if p.VarChanged != nil {
+		p.VarChanged(name, nil)
+	}
+	p.emit(Event{Kind: EventAssigned, Name: name})
+}
@@ -0,0 +1,13 @@
+package interp
+
+// wrap truncates an integer to IntWidth bits, sign extending the result,
+// so that arithmetic overflows as it would on the machine emulated.
+// Other values are returned unchanged.
+func (p *Interpreter) wrap(v Value) Value {
+	n, ok := v.(Int)
+	if !ok || p.IntWidth <= 0 || p.IntWidth >= 64 {
+		return v
+	}
+	shift := uint(64 - p.IntWidth)
+	return n << shift >> shift
+}
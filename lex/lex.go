@@ -9,31 +9,70 @@ const (
 	STRING
 	VARIABLE
 	LET
+	CONST
+	GET
+	LOCAL
 	PRINT
+	INPUT
 	IF
 	THEN
 	ELSE
+	ELSEIF
 	FOR
 	TO
 	NEXT
+	WHILE
+	WEND
 	GOTO
 	GOSUB
 	RETURN
+	RUN
+	ON
+	RESUME
+	TRON
+	TROFF
 	CALL
+	SUB
+	FUNCTION
+	INCLUDE
+	OPEN
+	CLOSE
+	CHAIN
+	POP
+	CLEAR
+	SYS
+	WAIT
 	REM
 	PEEK
 	POKE
+	POKE8
+	POKE16
+	POKE32
 	END
+	CLS
+	DIM
+	PLOT
+	LOCATE
+	COLOR
 	COMMA
 	SEMICOLON
+	COLON
 	PLUS
 	MINUS
 	AND
 	OR
 	XOR
+	POW
+	NOT
+	LAND
+	LOR
+	TILDE
 	ASTR
 	SLASH
+	BACKSLASH
 	MOD
+	SHL
+	SHR
 	HASH
 	LPAREN
 	RPAREN
@@ -14,46 +14,85 @@ func _() {
 	_ = x[STRING-3]
 	_ = x[VARIABLE-4]
 	_ = x[LET-5]
-	_ = x[PRINT-6]
-	_ = x[IF-7]
-	_ = x[THEN-8]
-	_ = x[ELSE-9]
-	_ = x[FOR-10]
-	_ = x[TO-11]
-	_ = x[NEXT-12]
-	_ = x[GOTO-13]
-	_ = x[GOSUB-14]
-	_ = x[RETURN-15]
-	_ = x[CALL-16]
-	_ = x[REM-17]
-	_ = x[PEEK-18]
-	_ = x[POKE-19]
-	_ = x[END-20]
-	_ = x[COMMA-21]
-	_ = x[SEMICOLON-22]
-	_ = x[PLUS-23]
-	_ = x[MINUS-24]
-	_ = x[AND-25]
-	_ = x[OR-26]
-	_ = x[XOR-27]
-	_ = x[ASTR-28]
-	_ = x[SLASH-29]
-	_ = x[MOD-30]
-	_ = x[HASH-31]
-	_ = x[LPAREN-32]
-	_ = x[RPAREN-33]
-	_ = x[LT-34]
-	_ = x[GT-35]
-	_ = x[LEQ-36]
-	_ = x[GEQ-37]
-	_ = x[NEQ-38]
-	_ = x[EQ-39]
-	_ = x[CR-40]
+	_ = x[CONST-6]
+	_ = x[GET-7]
+	_ = x[LOCAL-8]
+	_ = x[PRINT-9]
+	_ = x[INPUT-10]
+	_ = x[IF-11]
+	_ = x[THEN-12]
+	_ = x[ELSE-13]
+	_ = x[ELSEIF-14]
+	_ = x[FOR-15]
+	_ = x[TO-16]
+	_ = x[NEXT-17]
+	_ = x[WHILE-18]
+	_ = x[WEND-19]
+	_ = x[GOTO-20]
+	_ = x[GOSUB-21]
+	_ = x[RETURN-22]
+	_ = x[RUN-23]
+	_ = x[ON-24]
+	_ = x[RESUME-25]
+	_ = x[TRON-26]
+	_ = x[TROFF-27]
+	_ = x[CALL-28]
+	_ = x[SUB-29]
+	_ = x[FUNCTION-30]
+	_ = x[INCLUDE-31]
+	_ = x[OPEN-32]
+	_ = x[CLOSE-33]
+	_ = x[CHAIN-34]
+	_ = x[POP-35]
+	_ = x[CLEAR-36]
+	_ = x[SYS-37]
+	_ = x[WAIT-38]
+	_ = x[REM-39]
+	_ = x[PEEK-40]
+	_ = x[POKE-41]
+	_ = x[POKE8-42]
+	_ = x[POKE16-43]
+	_ = x[POKE32-44]
+	_ = x[END-45]
+	_ = x[CLS-46]
+	_ = x[DIM-47]
+	_ = x[PLOT-48]
+	_ = x[LOCATE-49]
+	_ = x[COLOR-50]
+	_ = x[COMMA-51]
+	_ = x[SEMICOLON-52]
+	_ = x[COLON-53]
+	_ = x[PLUS-54]
+	_ = x[MINUS-55]
+	_ = x[AND-56]
+	_ = x[OR-57]
+	_ = x[XOR-58]
+	_ = x[POW-59]
+	_ = x[NOT-60]
+	_ = x[LAND-61]
+	_ = x[LOR-62]
+	_ = x[TILDE-63]
+	_ = x[ASTR-64]
+	_ = x[SLASH-65]
+	_ = x[BACKSLASH-66]
+	_ = x[MOD-67]
+	_ = x[SHL-68]
+	_ = x[SHR-69]
+	_ = x[HASH-70]
+	_ = x[LPAREN-71]
+	_ = x[RPAREN-72]
+	_ = x[LT-73]
+	_ = x[GT-74]
+	_ = x[LEQ-75]
+	_ = x[GEQ-76]
+	_ = x[NEQ-77]
+	_ = x[EQ-78]
+	_ = x[CR-79]
 }
 
-const _Token_name = "ERROREOFNUMBERSTRINGVARIABLELETPRINTIFTHENELSEFORTONEXTGOTOGOSUBRETURNCALLREMPEEKPOKEENDCOMMASEMICOLONPLUSMINUSANDORXORASTRSLASHMODHASHLPARENRPARENLTGTLEQGEQNEQEQCR"
+const _Token_name = "ERROREOFNUMBERSTRINGVARIABLELETCONSTGETLOCALPRINTINPUTIFTHENELSEELSEIFFORTONEXTWHILEWENDGOTOGOSUBRETURNRUNONRESUMETRONTROFFCALLSUBFUNCTIONINCLUDEOPENCLOSECHAINPOPCLEARSYSWAITREMPEEKPOKEPOKE8POKE16POKE32ENDCLSDIMPLOTLOCATECOLORCOMMASEMICOLONCOLONPLUSMINUSANDORXORPOWNOTLANDLORTILDEASTRSLASHBACKSLASHMODSHLSHRHASHLPARENRPARENLTGTLEQGEQNEQEQCR"
 
-var _Token_index = [...]uint8{0, 5, 8, 14, 20, 28, 31, 36, 38, 42, 46, 49, 51, 55, 59, 64, 70, 74, 77, 81, 85, 88, 93, 102, 106, 111, 114, 116, 119, 123, 128, 131, 135, 141, 147, 149, 151, 154, 157, 160, 162, 164}
+var _Token_index = [...]uint16{0, 5, 8, 14, 20, 28, 31, 36, 39, 44, 49, 54, 56, 60, 64, 70, 73, 75, 79, 84, 88, 92, 97, 103, 106, 108, 114, 118, 123, 127, 130, 138, 145, 149, 154, 159, 162, 167, 170, 174, 177, 181, 185, 190, 196, 202, 205, 208, 211, 215, 221, 226, 231, 240, 245, 249, 254, 257, 259, 262, 265, 268, 272, 275, 280, 284, 289, 298, 301, 304, 307, 311, 317, 323, 325, 327, 330, 333, 336, 338, 340}
 
 func (i Token) String() string {
 	if i < 0 || i >= Token(len(_Token_index)-1) {
@@ -2,6 +2,7 @@ package lex
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"text/scanner"
 	"unicode"
@@ -10,6 +11,11 @@ import (
 
 type Config struct {
 	ScanComments bool
+
+	// Exponent makes ^ the exponentiation operator, as in Microsoft
+	// BASIC, and the word XOR the exclusive or operator. Otherwise ^ is
+	// exclusive or.
+	Exponent bool
 }
 
 type Tokenizer struct {
@@ -17,46 +23,93 @@ type Tokenizer struct {
 	name string
 	src  []byte
 
-	ch                 rune
-	offset             int
-	rdOffset           int
-	line, lastLine     int
-	column, lastColumn int
+	// When reading from a stream, src holds only the input from the
+	// start of the current token (mark) onwards and base is the offset of
+	// src[0] within the stream.
+	r    io.Reader
+	base int
+	mark int
+
+	// ch is the current character, at offset in src, and line and
+	// column are its position.
+	ch           rune
+	offset       int
+	rdOffset     int
+	line, column int
 }
 
 func (t *Tokenizer) Init(conf Config, name string, src []byte) {
 	*t = Tokenizer{
-		conf:   conf,
-		name:   name,
-		src:    src,
-		line:   1,
-		column: 1,
+		conf: conf,
+		name: name,
+		src:  src,
+		line: 1,
+	}
+	t.next()
+}
+
+// InitReader prepares the tokenizer to read source incrementally from r,
+// so arbitrarily long inputs can be tokenized without holding them in
+// memory all at once.
+func (t *Tokenizer) InitReader(conf Config, name string, r io.Reader) {
+	*t = Tokenizer{
+		conf: conf,
+		name: name,
+		r:    r,
+		line: 1,
 	}
 	t.next()
 }
 
+// fill reads more of the stream into src, discarding input before the
+// current token. It reports false at the end of the stream.
+func (t *Tokenizer) fill() bool {
+	if t.r == nil {
+		return false
+	}
+
+	if t.mark > 0 {
+		n := copy(t.src, t.src[t.mark:])
+		t.src = t.src[:n]
+		t.base += t.mark
+		t.offset -= t.mark
+		t.rdOffset -= t.mark
+		t.mark = 0
+	}
+
+	var buf [4096]byte
+	n, err := t.r.Read(buf[:])
+	t.src = append(t.src, buf[:n]...)
+	if err != nil && n == 0 {
+		t.r = nil
+		return false
+	}
+	return true
+}
+
 const (
 	eof = -1
 )
 
 func (t *Tokenizer) next() {
-	t.lastLine = t.line
-	t.lastColumn = t.column
+	for t.r != nil && len(t.src)-t.rdOffset < utf8.UTFMax {
+		if !t.fill() {
+			break
+		}
+	}
+	if t.ch == '\n' {
+		t.line++
+		t.column = 1
+	} else {
+		t.column++
+	}
 	if t.rdOffset < len(t.src) {
 		t.offset = t.rdOffset
-		if t.ch == '\n' {
-			t.line++
-			t.column = 1
-		}
 		r, w := utf8.DecodeRune(t.src[t.rdOffset:])
 		t.rdOffset += w
 		t.ch = r
 	} else {
 		t.offset = len(t.src)
-		if t.ch == '\n' {
-			t.line++
-			t.column = 1
-		}
 		t.ch = eof
 	}
 }
@@ -64,17 +117,21 @@ func (t *Tokenizer) next() {
 func (t *Tokenizer) Next() (pos scanner.Position, tok Token, lit string) {
 scan:
 	t.skipws()
+	t.mark = t.offset
 
 	pos = scanner.Position{
 		Filename: t.name,
-		Offset:   t.offset,
-		Line:     t.lastLine,
-		Column:   t.lastColumn,
+		Offset:   t.abs(),
+		Line:     t.line,
+		Column:   t.column,
 	}
 	switch ch := t.ch; {
 	case isLetter(ch):
 		lit = t.ident()
 		tok = lookupIdent(lit)
+		if tok == VARIABLE && t.conf.Exponent && strings.EqualFold(lit, "xor") {
+			tok = XOR
+		}
 		if tok == REM {
 			lit += t.comment()
 			if !t.conf.ScanComments {
@@ -85,6 +142,11 @@ scan:
 		tok, lit = t.number()
 	case ch == '"':
 		tok, lit = t.string()
+	case ch == '\'':
+		tok, lit = REM, t.comment()
+		if !t.conf.ScanComments {
+			goto scan
+		}
 	case ch == eof:
 		tok = EOF
 	default:
@@ -98,12 +160,18 @@ scan:
 			tok = COMMA
 		case ';':
 			tok = SEMICOLON
+		case ':':
+			tok = COLON
 		case '<':
 			tok = LT
 			if t.ch == '=' {
 				tok = LEQ
 				lit = "<="
 				t.next()
+			} else if t.ch == '<' {
+				tok = SHL
+				lit = "<<"
+				t.next()
 			}
 		case '>':
 			tok = GT
@@ -111,6 +179,10 @@ scan:
 				tok = GEQ
 				lit = ">="
 				t.next()
+			} else if t.ch == '>' {
+				tok = SHR
+				lit = ">>"
+				t.next()
 			}
 		case '!':
 			if t.ch == '=' {
@@ -126,8 +198,16 @@ scan:
 			tok = RPAREN
 		case '^':
 			tok = XOR
+			if t.conf.Exponent {
+				tok = POW
+			}
+		case '~':
+			tok = TILDE
 		case '&':
 			tok = AND
+			if t.prefixed("hH", isHexDigit) || t.prefixed("bB", isBinDigit) {
+				tok, lit = NUMBER, t.text(t.base+t.mark)
+			}
 		case '|':
 			tok = OR
 		case '+':
@@ -138,6 +218,8 @@ scan:
 			tok = ASTR
 		case '/':
 			tok = SLASH
+		case '\\':
+			tok = BACKSLASH
 		case '%':
 			tok = MOD
 		case '#':
@@ -156,13 +238,25 @@ func (t *Tokenizer) skipws() {
 	}
 }
 
+// comment consumes the rest of the line, leaving the newline ending it to
+// be scanned as the end of the statement.
 func (t *Tokenizer) comment() string {
-	offs := t.offset
-	for t.ch != '\n' {
+	offs := t.abs()
+	for t.ch != '\n' && t.ch != eof {
 		t.next()
 	}
-	t.next()
-	return string(t.src[offs:t.offset])
+	return t.text(offs)
+}
+
+// abs returns the offset of the current character within the input.
+func (t *Tokenizer) abs() int {
+	return t.base + t.offset
+}
+
+// text returns the input from the absolute offset offs up to the current
+// character.
+func (t *Tokenizer) text(offs int) string {
+	return string(t.src[offs-t.base : t.offset])
 }
 
 func isLetter(ch rune) bool {
@@ -173,63 +267,175 @@ func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func isBinDigit(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
 func (t *Tokenizer) ident() string {
-	offs := t.offset
+	offs := t.abs()
 	for isLetter(t.ch) || isDigit(t.ch) {
 		t.next()
 	}
-	return string(t.src[offs:t.offset])
+	if t.ch == '$' {
+		t.next()
+	}
+	return t.text(offs)
 }
 
 func lookupIdent(ident string) Token {
 	switch strings.ToLower(ident) {
 	case "let":
 		return LET
+	case "local":
+		return LOCAL
+	case "const":
+		return CONST
+	case "get":
+		return GET
+	case "cls":
+		return CLS
+	case "dim":
+		return DIM
+	case "plot":
+		return PLOT
+	case "locate":
+		return LOCATE
+	case "color":
+		return COLOR
 	case "print":
 		return PRINT
+	case "input":
+		return INPUT
 	case "if":
 		return IF
 	case "then":
 		return THEN
 	case "else":
 		return ELSE
+	case "elseif":
+		return ELSEIF
 	case "for":
 		return FOR
 	case "to":
 		return TO
 	case "next":
 		return NEXT
+	case "while":
+		return WHILE
+	case "wend":
+		return WEND
 	case "goto":
 		return GOTO
 	case "gosub":
 		return GOSUB
 	case "return":
 		return RETURN
+	case "run":
+		return RUN
+	case "on":
+		return ON
+	case "resume":
+		return RESUME
+	case "tron":
+		return TRON
+	case "troff":
+		return TROFF
 	case "call":
 		return CALL
+	case "sub":
+		return SUB
+	case "function":
+		return FUNCTION
+	case "include":
+		return INCLUDE
+	case "open":
+		return OPEN
+	case "close":
+		return CLOSE
+	case "chain":
+		return CHAIN
+	case "pop":
+		return POP
+	case "clear":
+		return CLEAR
+	case "sys":
+		return SYS
+	case "wait":
+		return WAIT
 	case "rem":
 		return REM
 	case "peek":
 		return PEEK
 	case "poke":
 		return POKE
+	case "poke8":
+		return POKE8
+	case "poke16":
+		return POKE16
+	case "poke32":
+		return POKE32
 	case "end":
 		return END
+	case "not":
+		return NOT
+	case "and":
+		return LAND
+	case "or":
+		return LOR
 	default:
 		return VARIABLE
 	}
 }
 
 func (t *Tokenizer) number() (Token, string) {
-	offs := t.offset
+	offs := t.abs()
+	if t.ch == '0' {
+		t.next()
+		if t.prefixed("xX", isHexDigit) {
+			return NUMBER, t.text(offs)
+		}
+	}
 	for isDigit(t.ch) {
 		t.next()
 	}
-	return NUMBER, string(t.src[offs:t.offset])
+	return NUMBER, t.text(offs)
+}
+
+// prefixed consumes a radix prefix character from set followed by digits
+// accepted by valid, as in the H of &HFF. Nothing is consumed unless at
+// least one digit follows the prefix, so that a&b still means a & b.
+func (t *Tokenizer) prefixed(set string, valid func(rune) bool) bool {
+	if !strings.ContainsRune(set, t.ch) || !valid(t.peek()) {
+		return false
+	}
+	t.next()
+	for valid(t.ch) {
+		t.next()
+	}
+	return true
+}
+
+// peek returns the character after the current one without consuming
+// anything.
+func (t *Tokenizer) peek() rune {
+	for t.r != nil && len(t.src)-t.rdOffset < utf8.UTFMax {
+		if !t.fill() {
+			break
+		}
+	}
+	if t.rdOffset >= len(t.src) {
+		return eof
+	}
+	r, _ := utf8.DecodeRune(t.src[t.rdOffset:])
+	return r
 }
 
 func (t *Tokenizer) string() (Token, string) {
-	offs := t.offset
+	offs := t.abs()
 	for {
 		t.next()
 		if t.ch == eof || t.ch == '\r' || t.ch == '\n' {
@@ -240,5 +446,5 @@ func (t *Tokenizer) string() (Token, string) {
 		}
 	}
 	t.next()
-	return STRING, string(t.src[offs:t.offset])
+	return STRING, t.text(offs)
 }
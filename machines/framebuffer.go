@@ -0,0 +1,144 @@
+// Package machines provides Mach implementations that give programs
+// something to PEEK and POKE at.
+package machines
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+// Palette holds the colors pixel values select, those of the PC's 16
+// color text modes. Pixel values are taken modulo its length.
+var Palette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xff},
+	color.RGBA{0x00, 0x00, 0xaa, 0xff},
+	color.RGBA{0x00, 0xaa, 0x00, 0xff},
+	color.RGBA{0x00, 0xaa, 0xaa, 0xff},
+	color.RGBA{0xaa, 0x00, 0x00, 0xff},
+	color.RGBA{0xaa, 0x00, 0xaa, 0xff},
+	color.RGBA{0xaa, 0x55, 0x00, 0xff},
+	color.RGBA{0xaa, 0xaa, 0xaa, 0xff},
+	color.RGBA{0x55, 0x55, 0x55, 0xff},
+	color.RGBA{0x55, 0x55, 0xff, 0xff},
+	color.RGBA{0x55, 0xff, 0x55, 0xff},
+	color.RGBA{0x55, 0xff, 0xff, 0xff},
+	color.RGBA{0xff, 0x55, 0x55, 0xff},
+	color.RGBA{0xff, 0x55, 0xff, 0xff},
+	color.RGBA{0xff, 0xff, 0x55, 0xff},
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+}
+
+// Framebuffer is a machine with a bitmap display mapped into memory. The
+// Width*Height addresses from Base hold one pixel each, row by row; all
+// other accesses and output go to the underlying Mach.
+//
+// The display can be saved with WritePNG, or served over HTTP since a
+// Framebuffer is an http.Handler, so programs from retro graphics
+// tutorials can be watched as they run.
+type Framebuffer struct {
+	interp.Mach
+	Base          int64
+	Width, Height int
+
+	// Scale is the size of a pixel in the rendered image.
+	Scale int
+
+	mu     sync.Mutex
+	pixels []byte
+}
+
+// Flush flushes the underlying Mach if it buffers output.
+func (f *Framebuffer) Flush() error {
+	if m, ok := f.Mach.(interp.FlushMach); ok {
+		return m.Flush()
+	}
+	return nil
+}
+
+// NewFramebuffer returns a Framebuffer of the given size at base that
+// passes everything else through to mach.
+func NewFramebuffer(mach interp.Mach, base int64, width, height int) *Framebuffer {
+	return &Framebuffer{
+		Mach:   mach,
+		Base:   base,
+		Width:  width,
+		Height: height,
+		Scale:  4,
+		pixels: make([]byte, width*height),
+	}
+}
+
+func (f *Framebuffer) pixel(addr int64) (int, bool) {
+	i := addr - f.Base
+	if i < 0 || i >= int64(len(f.pixels)) {
+		return 0, false
+	}
+	return int(i), true
+}
+
+func (f *Framebuffer) Peek(addr int64) int64 {
+	i, ok := f.pixel(addr)
+	if !ok {
+		return f.Mach.Peek(addr)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(f.pixels[i])
+}
+
+func (f *Framebuffer) Poke(addr, value int64) {
+	i, ok := f.pixel(addr)
+	if !ok {
+		f.Mach.Poke(addr, value)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pixels[i] = byte(value)
+}
+
+// Read reads from the underlying Mach if it supports input, so INPUT
+// keeps working.
+func (f *Framebuffer) Read(b []byte) (int, error) {
+	if r, ok := f.Mach.(io.Reader); ok {
+		return r.Read(b)
+	}
+	return 0, io.EOF
+}
+
+// Image returns a snapshot of the display.
+func (f *Framebuffer) Image() *image.Paletted {
+	scale := f.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	m := image.NewPaletted(image.Rect(0, 0, f.Width*scale, f.Height*scale), Palette)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for y := 0; y < m.Rect.Dy(); y++ {
+		for x := 0; x < m.Rect.Dx(); x++ {
+			v := f.pixels[(y/scale)*f.Width+x/scale]
+			m.SetColorIndex(x, y, v%uint8(len(Palette)))
+		}
+	}
+	return m
+}
+
+// WritePNG writes the display to w as a PNG image.
+func (f *Framebuffer) WritePNG(w io.Writer) error {
+	return png.Encode(w, f.Image())
+}
+
+// ServeHTTP serves the current display as a PNG image.
+func (f *Framebuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	f.WritePNG(w)
+}
@@ -1,46 +1,281 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"os/signal"
+	"strings"
 
+	_ "github.com/qeedquan/go-ubasic/ext/httpext"
+	_ "github.com/qeedquan/go-ubasic/ext/jsonext"
+	_ "github.com/qeedquan/go-ubasic/ext/regexpext"
 	"github.com/qeedquan/go-ubasic/interp"
+	"github.com/qeedquan/go-ubasic/machines"
+	"github.com/qeedquan/go-ubasic/parse"
+)
+
+// Exit statuses, so that wrappers can tell what kind of failure occurred.
+const (
+	exitRuntime = 1
+	exitUsage   = 2
+	exitParse   = 3
+	exitLimit   = 4
 )
 
 var (
+	stream      = flag.Bool("stream", false, "execute files as they are read instead of loading them first")
+	allow       = flag.String("allow", "", "comma separated `policies` whose builtins programs may use, such as net or regexp")
+	framebuffer = flag.String("framebuffer", "", "map a 64x48 framebuffer at address 4096 and save it to `file` as a PNG when done")
+	memFile     = flag.String("memfile", "", "use the bytes of `file` as the memory PEEK and POKE access, creating it if needed")
+	dryRun      = flag.Bool("n", false, "check files for probable errors without running them")
+	strict      = flag.Bool("strict", false, "report FOR and WHILE loops still open when a program ends")
+	strictVars  = flag.Bool("strictvars", true, "report reading a variable never assigned as an error instead of reading 0 or \"\"")
+	more        = flag.Bool("more", true, "pause output after each screenful when running in a terminal")
+	profile     = flag.Bool("profile", false, "write the time spent on each line of programs to standard error when they end")
+	timeout     = flag.Duration("timeout", 0, "stop programs that run for longer than `duration`")
+	maxSteps    = flag.Int64("maxsteps", 0, "stop programs after executing `n` statements (0 for no limit)")
+	options     = flag.String("options", "", "`options` applied to programs after their own, such as \"intwidth=16 truevalue=-1\"")
+	cover       = flag.Bool("cover", false, "write the share of lines of programs executed, and the lines never executed, to standard error when they end")
+	record      = flag.String("record", "", "write the PEEK values, INPUT lines, RND numbers and keys programs read to `file`, to be replayed")
+	replay      = flag.String("replay", "", "take the PEEK values, INPUT lines, RND numbers and keys programs read from `file`, written by -record")
+	determ      = flag.Bool("deterministic", false, "seed RND with 1 and stop the clock so that programs behave the same on every run")
+	showVersion = flag.Bool("version", false, "print version information and exit")
+	jsonOutput  = flag.Bool("json", false, "with -version, describe the supported statements, builtins, dialects and limits as JSON")
+
 	status = 0
+	fb     *machines.Framebuffer
+	mem    *interp.FileMach
 )
 
 func main() {
 	flag.Usage = usage
 	flag.Parse()
 
-	if flag.NArg() == 0 {
-		ek(interp.Repl(interp.NewStdio(), os.Stdin))
+	if *showVersion {
+		printVersion(*jsonOutput)
+	} else if flag.NArg() == 0 {
+		ek(newInterpreter().Repl(os.Stdin))
+	} else if flag.Arg(0) == "run" {
+		batch(flag.Args()[1:])
+	} else if flag.Arg(0) == "diff" {
+		compare(flag.Args()[1:])
 	} else {
+		// An interrupt stops the program running with an error, as
+		// the deadline of a context would, and skips any after it.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
 		for _, name := range flag.Args() {
-			src, err := ioutil.ReadFile(name)
+			if ctx.Err() != nil {
+				break
+			}
+			if *stream {
+				ek(runStream(ctx, name))
+				continue
+			}
+
+			src, err := interp.ReadFile(name)
 			if ek(err) {
 				continue
 			}
-			ek(interp.Run(interp.NewStdio(), name, src))
+			if *dryRun {
+				check(name, src)
+				continue
+			}
+			prog, err := interp.CompileWith(name, src, *options)
+			if ek(err) {
+				continue
+			}
+			p := newInterpreter()
+			var pr *interp.Profiler
+			if *profile {
+				pr = interp.NewProfiler()
+				p.Tracer = pr
+			}
+			var journal *interp.Journal
+			if *record != "" {
+				journal = p.RecordEffects()
+			}
+			if *replay != "" && ek(replayEffects(p, *replay)) {
+				continue
+			}
+			var cov *interp.Coverage
+			if *cover {
+				cov = interp.NewCoverage()
+				p.Coverage = cov
+			}
+			ek(p.RunContext(ctx, prog))
+			if pr != nil {
+				pr.Report().WriteTo(os.Stderr)
+			}
+			if cov != nil {
+				writeCoverage(name, cov, prog)
+			}
+			if journal != nil {
+				ek(writeJournal(*record, journal))
+			}
 		}
 	}
+	if fb != nil {
+		ek(saveFramebuffer(*framebuffer))
+	}
+	if mem != nil {
+		ek(mem.Close())
+	}
 	os.Exit(status)
 }
 
+// replayEffects makes p replay the journal in the file name.
+func replayEffects(p *interp.Interpreter, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	j, err := interp.ReadJournal(f)
+	if err != nil {
+		return err
+	}
+	p.ReplayEffects(j)
+	return nil
+}
+
+// writeJournal writes j to the file name.
+func writeJournal(name string, j *interp.Journal) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := j.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeCoverage writes the share of the lines of prog that cov saw
+// executed to standard error, followed by those that were not.
+func writeCoverage(name string, cov *interp.Coverage, prog *interp.Program) {
+	n, total := cov.Count(prog.Lines)
+	percent := 100.0
+	if total > 0 {
+		percent = 100 * float64(n) / float64(total)
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d of %d lines executed (%.1f%%)\n", name, n, total, percent)
+	if missed := cov.Missed(prog.Lines); len(missed) > 0 {
+		fmt.Fprintf(os.Stderr, "not executed: %v\n", strings.Trim(fmt.Sprint(missed), "[]"))
+	}
+}
+
+func saveFramebuffer(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := fb.WritePNG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runStream(ctx context.Context, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := interp.NewReader(f)
+	if err != nil {
+		return err
+	}
+	p := newInterpreter()
+	p.Context = ctx
+	return p.RunStreamWith(name, r, *options)
+}
+
+// newInterpreter returns an interpreter for the standard machine with the
+// settings given on the command line.
+func newInterpreter() *interp.Interpreter {
+	var mach interp.Mach = interp.NewStdio()
+	if *memFile != "" {
+		if mem == nil {
+			var err error
+			if mem, err = interp.OpenFileMach(mach, *memFile); err != nil {
+				fmt.Fprintln(os.Stderr, "ubasic:", err)
+				os.Exit(exitUsage)
+			}
+		}
+		mach = mem
+	}
+	if *framebuffer != "" {
+		fb = machines.NewFramebuffer(mach, 4096, 64, 48)
+		mach = fb
+	}
+	p := interp.NewInterpreter(mach,
+		interp.WithStrictEnd(*strict),
+		interp.WithStrictVars(*strictVars),
+		interp.WithTimeout(*timeout),
+		interp.WithMaxSteps(*maxSteps),
+		interp.WithFiles(interp.OSFileSystem{}),
+	)
+	if *determ {
+		p.SetDeterministic(interp.Deterministic{Seed: 1})
+	}
+	p.Paginate = *more && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+	p.Allow = make(map[string]bool)
+	for _, policy := range strings.Split(*allow, ",") {
+		if policy != "" {
+			p.Allow[policy] = true
+		}
+	}
+	return p
+}
+
+// isTerminal reports whether f is a character device, such as a terminal,
+// rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func check(name string, src []byte) {
+	prog, err := interp.CompileWith(name, src, *options)
+	if ek(err) {
+		return
+	}
+	for _, err := range interp.DryRun(prog) {
+		ek(err)
+	}
+}
+
 func usage() {
 	fmt.Fprintln(os.Stderr, "usage: [file] ...")
+	fmt.Fprintln(os.Stderr, "       run [options] dir ...")
+	fmt.Fprintln(os.Stderr, "       diff [options] dir ...")
 	flag.PrintDefaults()
-	os.Exit(2)
+	os.Exit(exitUsage)
+}
+
+func exitCode(err error) int {
+	var perr *parse.Error
+	switch {
+	case errors.As(err, &perr):
+		return exitParse
+	case errors.Is(err, interp.ErrLimit):
+		return exitLimit
+	}
+	return exitRuntime
 }
 
 func ek(err error) bool {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "ubasic:", err)
-		status = 1
+		status = exitCode(err)
 		return true
 	}
 	return false
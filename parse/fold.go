@@ -0,0 +1,124 @@
+package parse
+
+import (
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// fold evaluates the constant expression e to a number or string literal.
+func (p *Parser) fold(e ast.Expr) ast.Expr {
+	switch e := e.(type) {
+	case ast.Number, ast.String:
+		return e
+	case *ast.ParenExpr:
+		return p.fold(e.X)
+	case *ast.UnaryExpr:
+		x, ok := p.fold(e.X).(ast.Number)
+		if !ok {
+			p.errAt(e.Op.Pos, "operator %q is not defined on strings", e.Op.Type)
+		}
+		switch e.Op.Type {
+		case lex.MINUS:
+			x.Value = -x.Value
+		case lex.TILDE:
+			x.Value = ^x.Value
+		case lex.NOT:
+			x.Value = p.truth(x.Value == 0)
+		}
+		x.Pos = e.Op.Pos
+		return x
+	case *ast.BinaryExpr:
+		return p.foldBinary(e.Op, p.fold(e.X), p.fold(e.Y))
+	}
+	p.errAt(ast.ExprPos(e), "constant expression expected")
+	panic("unreachable")
+}
+
+func (p *Parser) foldBinary(op ast.Token, x, y ast.Expr) ast.Expr {
+	if a, ok := x.(ast.String); ok {
+		b, ok := y.(ast.String)
+		if !ok || op.Type != lex.PLUS {
+			p.errAt(op.Pos, "operator %q is not defined on these operands", op.Type)
+		}
+		a.Value += b.Value
+		return a
+	}
+	a, ok := x.(ast.Number)
+	b, ok2 := y.(ast.Number)
+	if !ok || !ok2 {
+		p.errAt(op.Pos, "operator %q is not defined on these operands", op.Type)
+	}
+
+	switch op.Type {
+	case lex.PLUS:
+		a.Value += b.Value
+	case lex.MINUS:
+		a.Value -= b.Value
+	case lex.ASTR:
+		a.Value *= b.Value
+	case lex.SLASH, lex.BACKSLASH, lex.MOD:
+		if b.Value == 0 {
+			p.errAt(op.Pos, "division by zero")
+		}
+		if op.Type == lex.MOD {
+			a.Value %= b.Value
+		} else {
+			a.Value /= b.Value
+		}
+	case lex.AND:
+		a.Value &= b.Value
+	case lex.OR:
+		a.Value |= b.Value
+	case lex.XOR:
+		a.Value ^= b.Value
+	case lex.POW:
+		if b.Value < 0 {
+			p.errAt(op.Pos, "negative exponent %d in constant", b.Value)
+		}
+		// As at run time, by squaring, so that large exponents are quick
+		// and overflow wraps around.
+		n, x := int64(1), a.Value
+		for e := b.Value; e > 0; e >>= 1 {
+			if e&1 != 0 {
+				n *= x
+			}
+			x *= x
+		}
+		a.Value = n
+	case lex.SHL, lex.SHR:
+		if b.Value < 0 {
+			p.errAt(op.Pos, "negative shift count %d", b.Value)
+		}
+		if op.Type == lex.SHL {
+			a.Value <<= uint64(b.Value)
+		} else {
+			a.Value >>= uint64(b.Value)
+		}
+	case lex.LT:
+		a.Value = p.truth(a.Value < b.Value)
+	case lex.GT:
+		a.Value = p.truth(a.Value > b.Value)
+	case lex.LEQ:
+		a.Value = p.truth(a.Value <= b.Value)
+	case lex.GEQ:
+		a.Value = p.truth(a.Value >= b.Value)
+	case lex.NEQ:
+		a.Value = p.truth(a.Value != b.Value)
+	case lex.EQ:
+		a.Value = p.truth(a.Value == b.Value)
+	default:
+		p.errAt(op.Pos, "operator %q is not allowed in constants", op.Type)
+	}
+	return a
+}
+
+// truth returns the value of a condition in a constant.
+func (p *Parser) truth(b bool) int64 {
+	switch {
+	case !b:
+		return 0
+	case p.TrueValue != 0:
+		return p.TrueValue
+	}
+	return 1
+}
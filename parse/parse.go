@@ -4,18 +4,43 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
+	"text/scanner"
 
 	"github.com/qeedquan/go-ubasic/ast"
 	"github.com/qeedquan/go-ubasic/lex"
 )
 
+// Error is a syntax error found while parsing.
+type Error ast.Error
+
+func (e *Error) Error() string {
+	return (*ast.Error)(e).Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 type Parser struct {
+	// AutoNumber makes line numbers optional. A line without one is
+	// numbered one past the line before it, so only lines that are
+	// jumped to need to be numbered; the numbers that are given must
+	// then increase.
+	AutoNumber bool
+
+	// TrueValue is the value true comparisons in constants fold to, as
+	// Interpreter.TrueValue is at run time. If it is 0, they fold to 1.
+	TrueValue int64
+
 	lex  *lex.Tokenizer
 	look []ast.Token
 	tok  ast.Token
 
-	label ast.Label
-	let   ast.Token
+	label  ast.Label
+	last   int64
+	let    ast.Token
+	consts map[string]ast.Expr
 }
 
 func NewParser(lex *lex.Tokenizer) *Parser {
@@ -34,7 +59,11 @@ func (p *Parser) Reset() {
 }
 
 func (p *Parser) errf(format string, args ...interface{}) {
-	err := &ast.Error{p.tok.Pos, fmt.Errorf(format, args...)}
+	p.errAt(p.tok.Pos, format, args...)
+}
+
+func (p *Parser) errAt(pos scanner.Position, format string, args ...interface{}) {
+	err := &Error{Pos: pos, Err: fmt.Errorf(format, args...)}
 	p.synch()
 	panic(err)
 }
@@ -75,9 +104,19 @@ func (p *Parser) accept(typ lex.Token) ast.Token {
 
 func (p *Parser) acceptNumber() ast.Number {
 	t := p.accept(lex.NUMBER)
-	n, err := strconv.ParseInt(t.Text, 0, 64)
+	text, base := t.Text, 0
+	if len(text) > 2 && text[0] == '&' {
+		switch text[1] {
+		case 'h', 'H':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		}
+		text = text[2:]
+	}
+	n, err := strconv.ParseInt(text, base, 64)
 	if err != nil {
-		p.errf("invalid number %q: %v", p.tok.Text, err)
+		p.errf("invalid number %q: %v", t.Text, err)
 	}
 
 	return ast.Number{
@@ -86,6 +125,19 @@ func (p *Parser) acceptNumber() ast.Number {
 	}
 }
 
+func (p *Parser) acceptString() ast.String {
+	t := p.accept(lex.STRING)
+	lit, err := strconv.Unquote(t.Text)
+	if err != nil {
+		p.errf("invalid string %q: %v", t.Text, err)
+	}
+
+	return ast.String{
+		Pos:   t.Pos,
+		Value: lit,
+	}
+}
+
 func (p *Parser) acceptVariable() ast.Variable {
 	t := p.accept(lex.VARIABLE)
 	return ast.Variable{
@@ -94,6 +146,15 @@ func (p *Parser) acceptVariable() ast.Variable {
 	}
 }
 
+// acceptTarget accepts a variable that is about to be assigned.
+func (p *Parser) acceptTarget() ast.Variable {
+	v := p.acceptVariable()
+	if _, ok := p.consts[v.Name]; ok {
+		p.errAt(v.Pos, "cannot assign to constant %v", v.Name)
+	}
+	return v
+}
+
 func (p *Parser) acceptCR() {
 	if p.tok.Type == lex.CR {
 		p.accept(lex.CR)
@@ -115,7 +176,7 @@ func (p *Parser) Line() (stmt ast.Stmt, err error) {
 	case lex.EOF:
 		return nil, io.EOF
 	case lex.ERROR:
-		p.errf(p.tok.Text)
+		p.errf("%s", p.tok.Text)
 		panic("unreachable")
 	default:
 		return p.stmt(), nil
@@ -131,71 +192,182 @@ func (p *Parser) skipcr() {
 func (p *Parser) stmt() ast.Stmt {
 	p.skipcr()
 
-	p.label = ast.Label(p.acceptNumber())
+	if p.tok.Type == lex.VARIABLE && !p.AutoNumber {
+		p.label = ast.Label{Pos: p.tok.Pos}
+		s := p.labelStmt(false)
+		p.acceptCR()
+		return s
+	}
+
+	p.label = ast.Label(p.lineNumber())
+	s, cr := p.body()
+	if cr {
+		p.acceptCR()
+	}
+
+	return s
+}
+
+// Continue makes the lines parsed from now on follow the given line
+// number in AutoNumber mode, as when the file is included at the end of
+// another.
+func (p *Parser) Continue(line int64) {
+	p.last = line
+}
+
+// lineNumber parses the number a line starts with, or makes one up for
+// an unnumbered line in AutoNumber mode.
+func (p *Parser) lineNumber() ast.Number {
+	if !p.AutoNumber {
+		return p.acceptNumber()
+	}
+	if p.tok.Type != lex.NUMBER {
+		p.last++
+		return ast.Number{Pos: p.tok.Pos, Value: p.last}
+	}
+	n := p.acceptNumber()
+	if n.Value <= p.last {
+		p.errAt(n.Pos, "line number %d must be greater than %d", n.Value, p.last)
+	}
+	p.last = n.Value
+	return n
+}
+
+// body parses the statement following a line number. It reports whether
+// the line terminator is still to be consumed, which is not the case for
+// an IF whose branches span several lines.
+func (p *Parser) body() (s ast.Stmt, cr bool) {
 	p.let = ast.Token{}
-	cr := true
+	cr = true
 
-	var s ast.Stmt
 	switch p.tok.Type {
+	case lex.CR, lex.EOF:
+		rem := &ast.RemStmt{}
+		rem.Label = p.label
+		s = rem
 	case lex.PRINT:
 		s = p.print()
+	case lex.INPUT:
+		s = p.input()
 	case lex.IF:
-		s = p.if_()
-		cr = false
+		s, cr = p.if_()
 	case lex.GOTO:
 		s = p.goto_()
 	case lex.GOSUB:
 		s = p.gosub()
 	case lex.RETURN:
 		s = p.return_()
+	case lex.RUN:
+		s = p.run()
+	case lex.ON:
+		s = p.onError()
+	case lex.RESUME:
+		s = p.resume()
 	case lex.FOR:
 		s = p.for_()
 	case lex.PEEK:
 		s = p.peek()
-	case lex.POKE:
+	case lex.POKE, lex.POKE8, lex.POKE16, lex.POKE32:
 		s = p.poke()
 	case lex.NEXT:
 		s = p.next_()
+	case lex.WHILE:
+		s = p.while_()
+	case lex.WEND:
+		s = p.wend()
 	case lex.END:
 		s = p.end()
+	case lex.LOCAL:
+		s = p.local()
+	case lex.CONST:
+		s = p.const_()
+	case lex.GET:
+		s = p.get()
+	case lex.CALL:
+		s = p.callStmt()
+	case lex.SUB:
+		s = p.sub()
+	case lex.FUNCTION:
+		s = p.function()
+	case lex.INCLUDE:
+		s = p.include()
+	case lex.OPEN:
+		s = p.open()
+	case lex.CLOSE:
+		s = p.close()
+	case lex.CHAIN:
+		s = p.chain()
+	case lex.POP:
+		s = p.pop()
+	case lex.CLEAR:
+		s = p.clear()
+	case lex.SYS:
+		s = p.sys()
+	case lex.CLS:
+		s = p.cls()
+	case lex.TRON, lex.TROFF:
+		s = p.trace()
+	case lex.DIM:
+		s = p.dim()
+	case lex.PLOT:
+		s = p.plot()
+	case lex.LOCATE:
+		s = p.locate()
+	case lex.COLOR:
+		s = p.color()
+	case lex.WAIT:
+		s = p.wait()
 	case lex.LET:
 		p.let = p.accept(lex.LET)
 		fallthrough
 	case lex.VARIABLE:
-		s = p.let_()
+		if p.peekType() == lex.COLON {
+			s = p.labelStmt(true)
+		} else {
+			s = p.let_()
+		}
 	default:
 		p.errf("unsupported statement %q", p.tok.Text)
 	}
-	if cr {
-		p.acceptCR()
-	}
 
-	return s
+	return
+}
+
+// inline parses a statement written on the same line after THEN or ELSE.
+// A bare line number is shorthand for GOTO.
+func (p *Parser) inline() (ast.Stmt, bool) {
+	if p.tok.Type == lex.NUMBER {
+		s := &ast.GotoStmt{}
+		s.Label = p.label
+		s.Goto = ast.Token{Pos: p.tok.Pos, Type: lex.GOTO}
+		s.Location = p.acceptNumber()
+		return s, true
+	}
+	return p.body()
 }
 
 func (p *Parser) print() *ast.PrintStmt {
 	s := &ast.PrintStmt{}
 	s.Label = p.label
 	s.Print = p.accept(lex.PRINT)
+	if p.tok.Type == lex.HASH {
+		s.Channel = p.channel()
+		if p.tok.Type == lex.COMMA {
+			p.next()
+		}
+	}
 
 loop:
 	for {
 		switch p.tok.Type {
-		case lex.STRING:
-			lit, err := strconv.Unquote(p.tok.Text)
-			if err != nil {
-				p.errf("invalid string %q: %v", p.tok.Text, err)
-			}
-			s.Args = append(s.Args, ast.String{p.tok.Pos, lit})
-			p.next()
 		case lex.COMMA, lex.SEMICOLON:
-			s.Args = append(s.Args, ast.Punct{p.tok.Pos, p.tok.Type})
+			s.Args = append(s.Args, ast.Punct{Pos: p.tok.Pos, Type: p.tok.Type})
 			p.next()
-		case lex.VARIABLE:
+		case lex.VARIABLE, lex.NUMBER, lex.STRING, lex.LPAREN, lex.PEEK:
 			s.Args = append(s.Args, p.expr())
-		case lex.NUMBER:
+		case lex.MINUS, lex.PLUS, lex.NOT, lex.TILDE:
 			s.Args = append(s.Args, p.expr())
-		case lex.CR, lex.EOF:
+		case lex.CR, lex.EOF, lex.ELSE:
 			break loop
 		default:
 			p.errf("unknown print type %q", p.tok.Text)
@@ -205,62 +377,279 @@ loop:
 	return s
 }
 
-func (p *Parser) if_() *ast.IfStmt {
-	s := &ast.IfStmt{}
+func (p *Parser) input() *ast.InputStmt {
+	s := &ast.InputStmt{}
+	s.Label = p.label
+	s.Input = p.accept(lex.INPUT)
+	if p.tok.Type == lex.HASH {
+		s.Channel = p.channel()
+		p.accept(lex.COMMA)
+	} else if p.tok.Type == lex.STRING {
+		prompt := p.acceptString()
+		s.Prompt = &prompt
+		switch p.tok.Type {
+		case lex.SEMICOLON, lex.COMMA:
+			s.Sep = ast.Punct{Pos: p.tok.Pos, Type: p.tok.Type}
+			p.next()
+		default:
+			p.errf("expected ; or , after input prompt, but got %q", p.tok.Text)
+		}
+	}
+	s.Vars = append(s.Vars, p.acceptTarget())
+	for p.tok.Type == lex.COMMA {
+		p.next()
+		s.Vars = append(s.Vars, p.acceptTarget())
+	}
+	return s
+}
+
+func (p *Parser) if_() (s *ast.IfStmt, cr bool) {
+	s = &ast.IfStmt{}
 	s.Label = p.label
 	s.If = p.accept(lex.IF)
-	s.Cond = p.relation()
+	s.Cond = p.cond()
 	s.Then = p.accept(lex.THEN)
-	p.acceptCR()
-	s.Body = p.stmt()
+	if p.tok.Type != lex.CR && p.tok.Type != lex.EOF {
+		s.Body, cr = p.inline()
+		if cr && p.tok.Type == lex.ELSE {
+			else_ := p.accept(lex.ELSE)
+			body, bodycr := p.inline()
 
-	tok := p.tok
-	num := p.acceptNumber()
-	if p.tok.Type == lex.ELSE {
-		else_ := p.accept(lex.ELSE)
-		p.acceptCR()
-		body := p.stmt()
-
-		s.Else = &ast.ElseStmt{
-			BaseStmt: ast.BaseStmt{
-				Label: ast.Label(num),
-			},
-			Else: else_,
-			Body: body,
+			s.Else = &ast.ElseStmt{
+				BaseStmt: ast.BaseStmt{
+					Label: s.Label,
+				},
+				Else: else_,
+				Body: body,
+			}
+			cr = bodycr
 		}
-	} else {
-		p.look = []ast.Token{p.tok}
-		p.tok = tok
+		return s, cr
 	}
 
-	return s
-}
+	p.acceptCR()
+	s.Body = p.stmt()
 
-func (p *Parser) relation() ast.Expr {
-	r1 := p.expr()
-loop:
 	for {
-		switch op := p.tok; op.Type {
-		case lex.LT, lex.GT, lex.LEQ, lex.GEQ, lex.NEQ, lex.EQ:
-			p.next()
-			r2 := p.expr()
-			r1 = &ast.BinaryExpr{
-				Op: op,
-				X:  r1,
-				Y:  r2,
+		p.skipcr()
+		tok, last := p.tok, p.last
+		switch {
+		case tok.Type == lex.NUMBER:
+		case p.AutoNumber && (tok.Type == lex.ELSEIF || tok.Type == lex.ELSE):
+		default:
+			return s, false
+		}
+
+		num := p.lineNumber()
+		switch p.tok.Type {
+		case lex.ELSEIF:
+			elseif := &ast.ElseIfStmt{}
+			elseif.Label = ast.Label(num)
+			elseif.ElseIf = p.accept(lex.ELSEIF)
+			elseif.Cond = p.cond()
+			elseif.Then = p.accept(lex.THEN)
+			p.acceptCR()
+			elseif.Body = p.stmt()
+			s.ElseIf = append(s.ElseIf, elseif)
+
+		case lex.ELSE:
+			else_ := p.accept(lex.ELSE)
+			p.acceptCR()
+			body := p.stmt()
+
+			s.Else = &ast.ElseStmt{
+				BaseStmt: ast.BaseStmt{
+					Label: ast.Label(num),
+				},
+				Else: else_,
+				Body: body,
 			}
+			return s, false
+
 		default:
-			break loop
+			p.look = []ast.Token{p.tok}
+			p.tok, p.last = tok, last
+			return s, false
 		}
 	}
-	return r1
 }
 
 func (p *Parser) goto_() *ast.GotoStmt {
 	s := &ast.GotoStmt{}
 	s.Label = p.label
 	s.Goto = p.accept(lex.GOTO)
-	s.Location = p.acceptNumber()
+	switch x := p.expr().(type) {
+	case ast.Number:
+		s.Location = x
+	case ast.Variable:
+		if strings.HasSuffix(x.Name, "$") {
+			s.Expr = x
+		} else {
+			s.Target = x
+		}
+	default:
+		s.Expr = x
+	}
+	return s
+}
+
+func (p *Parser) run() *ast.RunStmt {
+	s := &ast.RunStmt{}
+	s.Label = p.label
+	s.Run = p.accept(lex.RUN)
+	switch p.tok.Type {
+	case lex.VARIABLE:
+		s.Target = p.acceptVariable()
+	case lex.NUMBER:
+		n := p.acceptNumber()
+		s.Location = &n
+	}
+	return s
+}
+
+func (p *Parser) include() *ast.IncludeStmt {
+	s := &ast.IncludeStmt{}
+	s.Label = p.label
+	s.Include = p.accept(lex.INCLUDE)
+	s.Path = p.acceptString()
+	return s
+}
+
+func (p *Parser) open() *ast.OpenStmt {
+	s := &ast.OpenStmt{}
+	s.Label = p.label
+	s.Open = p.accept(lex.OPEN)
+	s.Name = p.expr()
+	s.For = p.accept(lex.FOR)
+	if p.tok.Type != lex.INPUT && !p.isWord("output") && !p.isWord("append") {
+		p.errf("expected INPUT, OUTPUT or APPEND, but got %q", p.tok.Text)
+	}
+	s.Mode = p.tok
+	p.next()
+	if !p.isWord("as") {
+		p.errf("expected AS, but got %q", p.tok.Text)
+	}
+	s.As = p.tok
+	p.next()
+	if p.tok.Type == lex.HASH {
+		s.Channel = p.channel()
+	} else {
+		s.Channel = p.expr()
+	}
+	return s
+}
+
+func (p *Parser) close() *ast.CloseStmt {
+	s := &ast.CloseStmt{}
+	s.Label = p.label
+	s.Close = p.accept(lex.CLOSE)
+	if p.tok.Type != lex.HASH {
+		return s
+	}
+	s.Channels = append(s.Channels, p.channel())
+	for p.tok.Type == lex.COMMA {
+		p.next()
+		s.Channels = append(s.Channels, p.channel())
+	}
+	return s
+}
+
+func (p *Parser) pop() *ast.PopStmt {
+	s := &ast.PopStmt{}
+	s.Label = p.label
+	s.Pop = p.accept(lex.POP)
+	return s
+}
+
+func (p *Parser) sys() *ast.SysStmt {
+	s := &ast.SysStmt{}
+	s.Label = p.label
+	s.Sys = p.accept(lex.SYS)
+	s.Addr = p.expr()
+	for p.tok.Type == lex.COMMA {
+		p.next()
+		s.Args = append(s.Args, p.expr())
+	}
+	return s
+}
+
+func (p *Parser) clear() *ast.ClearStmt {
+	s := &ast.ClearStmt{}
+	s.Label = p.label
+	s.Clear = p.accept(lex.CLEAR)
+	return s
+}
+
+func (p *Parser) chain() *ast.ChainStmt {
+	s := &ast.ChainStmt{}
+	s.Label = p.label
+	s.Chain = p.accept(lex.CHAIN)
+	s.Name = p.expr()
+	if p.tok.Type != lex.COMMA {
+		return s
+	}
+	p.next()
+	if p.tok.Type == lex.NUMBER {
+		n := p.acceptNumber()
+		s.Location = &n
+	}
+	if p.tok.Type != lex.COMMA {
+		return s
+	}
+	p.next()
+	if !p.isWord("all") {
+		p.errf("expected ALL, but got %q", p.tok.Text)
+	}
+	all := p.tok
+	s.All = &all
+	p.next()
+	return s
+}
+
+// channel parses a file channel number such as #1.
+func (p *Parser) channel() ast.Expr {
+	p.accept(lex.HASH)
+	return p.expr()
+}
+
+// isWord reports whether the current token is the given word, which is
+// not a keyword so that it can still be used as a variable name.
+func (p *Parser) isWord(word string) bool {
+	return p.tok.Type == lex.VARIABLE && strings.EqualFold(p.tok.Text, word)
+}
+
+func (p *Parser) onError() *ast.OnErrorStmt {
+	s := &ast.OnErrorStmt{}
+	s.Label = p.label
+	s.On = p.accept(lex.ON)
+	if p.tok.Type != lex.VARIABLE || !strings.EqualFold(p.tok.Text, "error") {
+		p.errf("expected ERROR after ON, but got %q", p.tok.Text)
+	}
+	s.Error = p.tok
+	p.next()
+	s.Goto = p.accept(lex.GOTO)
+	if p.tok.Type == lex.VARIABLE {
+		s.Target = p.acceptVariable()
+	} else {
+		s.Location = p.acceptNumber()
+	}
+	return s
+}
+
+func (p *Parser) resume() *ast.ResumeStmt {
+	s := &ast.ResumeStmt{}
+	s.Label = p.label
+	s.Resume = p.accept(lex.RESUME)
+	switch p.tok.Type {
+	case lex.NEXT:
+		next := p.accept(lex.NEXT)
+		s.Next = &next
+	case lex.VARIABLE:
+		s.Target = p.acceptVariable()
+	case lex.NUMBER:
+		n := p.acceptNumber()
+		s.Location = &n
+	}
 	return s
 }
 
@@ -268,15 +657,45 @@ func (p *Parser) gosub() *ast.GosubStmt {
 	s := &ast.GosubStmt{}
 	s.Label = p.label
 	s.Gosub = p.accept(lex.GOSUB)
-	s.Location = p.acceptNumber()
+	switch x := p.expr().(type) {
+	case ast.Number:
+		s.Location = x
+	case ast.Variable:
+		if strings.HasSuffix(x.Name, "$") {
+			s.Expr = x
+		} else {
+			s.Target = x
+		}
+	default:
+		s.Expr = x
+	}
 	return s
 }
 
+func (p *Parser) labelStmt(numbered bool) *ast.LabelStmt {
+	s := &ast.LabelStmt{}
+	s.Label = p.label
+	s.Name = p.acceptVariable()
+	s.Numbered = numbered
+	p.accept(lex.COLON)
+	return s
+}
+
+// peekType returns the type of the token after the current one.
+func (p *Parser) peekType() lex.Token {
+	tok := p.tok
+	p.next()
+	typ := p.tok.Type
+	p.look = append([]ast.Token{p.tok}, p.look...)
+	p.tok = tok
+	return typ
+}
+
 func (p *Parser) for_() *ast.ForStmt {
 	s := &ast.ForStmt{}
 	s.Label = p.label
 	s.For = p.accept(lex.FOR)
-	s.Var = p.acceptVariable()
+	s.Var = p.acceptTarget()
 	p.accept(lex.EQ)
 	s.Start = p.expr()
 	s.To = p.accept(lex.TO)
@@ -290,14 +709,23 @@ func (p *Parser) peek() *ast.PeekStmt {
 	s.Peek = p.accept(lex.PEEK)
 	s.Addr = p.expr()
 	p.accept(lex.COMMA)
-	s.Var = p.acceptVariable()
+	s.Var = p.acceptTarget()
 	return s
 }
 
 func (p *Parser) poke() *ast.PokeStmt {
 	s := &ast.PokeStmt{}
 	s.Label = p.label
-	s.Poke = p.accept(lex.POKE)
+	s.Poke = p.tok
+	switch p.tok.Type {
+	case lex.POKE8:
+		s.Size = 8
+	case lex.POKE16:
+		s.Size = 16
+	case lex.POKE32:
+		s.Size = 32
+	}
+	p.next()
 	s.Addr = p.expr()
 	p.accept(lex.COMMA)
 	s.Value = p.expr()
@@ -308,14 +736,47 @@ func (p *Parser) next_() *ast.NextStmt {
 	s := &ast.NextStmt{}
 	s.Label = p.label
 	s.Next = p.accept(lex.NEXT)
-	s.Var = p.acceptVariable()
+	if p.tok.Type == lex.VARIABLE {
+		s.Var = p.acceptVariable()
+	}
+	return s
+}
+
+func (p *Parser) while_() *ast.WhileStmt {
+	s := &ast.WhileStmt{}
+	s.Label = p.label
+	s.While = p.accept(lex.WHILE)
+	s.Cond = p.cond()
+	return s
+}
+
+func (p *Parser) wend() *ast.WendStmt {
+	s := &ast.WendStmt{}
+	s.Label = p.label
+	s.Wend = p.accept(lex.WEND)
 	return s
 }
 
-func (p *Parser) end() *ast.EndStmt {
+func (p *Parser) end() ast.Stmt {
+	end := p.accept(lex.END)
+	switch p.tok.Type {
+	case lex.SUB:
+		s := &ast.EndSubStmt{}
+		s.Label = p.label
+		s.End = end
+		s.Sub = p.accept(lex.SUB)
+		return s
+	case lex.FUNCTION:
+		s := &ast.EndFunctionStmt{}
+		s.Label = p.label
+		s.End = end
+		s.Function = p.accept(lex.FUNCTION)
+		return s
+	}
+
 	s := &ast.EndStmt{}
 	s.Label = p.label
-	s.End = p.accept(lex.END)
+	s.End = end
 	return s
 }
 
@@ -323,57 +784,193 @@ func (p *Parser) let_() *ast.LetStmt {
 	s := &ast.LetStmt{}
 	s.Label = p.label
 	s.Let = p.let
-	s.Var = p.acceptVariable()
+	s.Var = p.acceptTarget()
+	if p.tok.Type == lex.LPAREN {
+		s.Index = p.call(s.Var).Args
+	}
 	p.accept(lex.EQ)
 	s.Value = p.expr()
 	return s
 }
 
-func (p *Parser) return_() *ast.ReturnStmt {
-	s := &ast.ReturnStmt{}
+func (p *Parser) callStmt() *ast.CallStmt {
+	s := &ast.CallStmt{}
 	s.Label = p.label
-	s.Return = p.accept(lex.RETURN)
+	s.Call = p.accept(lex.CALL)
+	fn := p.acceptVariable()
+	if p.tok.Type == lex.LPAREN {
+		s.X = p.call(fn)
+	} else {
+		s.X = &ast.CallExpr{Func: fn}
+	}
 	return s
 }
 
-func (p *Parser) expr() ast.Expr {
-	t1 := p.term()
-loop:
-	for {
-		switch op := p.tok; op.Type {
-		case lex.PLUS, lex.MINUS, lex.AND, lex.OR:
-			p.next()
-			t2 := p.term()
-			t1 = &ast.BinaryExpr{
-				Op: op,
-				X:  t1,
-				Y:  t2,
+func (p *Parser) sub() *ast.SubStmt {
+	s := &ast.SubStmt{}
+	s.Label = p.label
+	s.Sub = p.accept(lex.SUB)
+	s.Name = p.acceptVariable()
+	if p.tok.Type == lex.LPAREN {
+		s.Params = p.params()
+	}
+	return s
+}
+
+func (p *Parser) function() *ast.FunctionStmt {
+	s := &ast.FunctionStmt{}
+	s.Label = p.label
+	s.Function = p.accept(lex.FUNCTION)
+	s.Name = p.acceptVariable()
+	if p.tok.Type == lex.LPAREN {
+		s.Params = p.params()
+	}
+	return s
+}
+
+// params parses the parenthesized parameter list of a SUB or FUNCTION.
+func (p *Parser) params() []ast.Variable {
+	var params []ast.Variable
+	p.accept(lex.LPAREN)
+	for p.tok.Type != lex.RPAREN {
+		if len(params) > 0 {
+			p.accept(lex.COMMA)
+		}
+		v := p.acceptTarget()
+		for _, param := range params {
+			if param.Name == v.Name {
+				p.errAt(v.Pos, "duplicate parameter %v", v.Name)
 			}
-		default:
-			break loop
 		}
+		params = append(params, v)
 	}
-	return t1
+	p.accept(lex.RPAREN)
+	return params
 }
 
-func (p *Parser) term() ast.Expr {
-	f1 := p.factor()
-loop:
-	for {
-		switch op := p.tok; op.Type {
-		case lex.ASTR, lex.SLASH, lex.MOD:
+func (p *Parser) trace() *ast.TraceStmt {
+	s := &ast.TraceStmt{}
+	s.Label = p.label
+	s.Trace = p.tok
+	p.next()
+	return s
+}
+
+func (p *Parser) wait() *ast.WaitStmt {
+	s := &ast.WaitStmt{}
+	s.Label = p.label
+	s.Wait = p.accept(lex.WAIT)
+	if p.tok.Type == lex.VARIABLE {
+		s.Name = p.acceptVariable()
+		if p.tok.Type == lex.COMMA {
 			p.next()
-			f2 := p.factor()
-			f1 = &ast.BinaryExpr{
-				Op: op,
-				X:  f1,
-				Y:  f2,
-			}
-		default:
-			break loop
+			v := p.acceptTarget()
+			s.Var = &v
+		}
+	}
+	return s
+}
+
+func (p *Parser) dim() *ast.DimStmt {
+	s := &ast.DimStmt{}
+	s.Label = p.label
+	s.Dim = p.accept(lex.DIM)
+	for {
+		s.Arrays = append(s.Arrays, p.call(p.acceptTarget()))
+		if p.tok.Type != lex.COMMA {
+			break
 		}
+		p.next()
+	}
+	return s
+}
+
+func (p *Parser) plot() *ast.PlotStmt {
+	s := &ast.PlotStmt{}
+	s.Label = p.label
+	s.Plot = p.accept(lex.PLOT)
+	s.X = p.acceptVariable()
+	if p.tok.Type == lex.COMMA {
+		p.next()
+		y := p.acceptVariable()
+		s.Y = &y
+	}
+	return s
+}
+
+func (p *Parser) cls() *ast.ClsStmt {
+	s := &ast.ClsStmt{}
+	s.Label = p.label
+	s.Cls = p.accept(lex.CLS)
+	return s
+}
+
+func (p *Parser) locate() *ast.LocateStmt {
+	s := &ast.LocateStmt{}
+	s.Label = p.label
+	s.Locate = p.accept(lex.LOCATE)
+	s.Row = p.expr()
+	p.accept(lex.COMMA)
+	s.Col = p.expr()
+	return s
+}
+
+func (p *Parser) color() *ast.ColorStmt {
+	s := &ast.ColorStmt{}
+	s.Label = p.label
+	s.Color = p.accept(lex.COLOR)
+	s.Fg = p.expr()
+	if p.tok.Type == lex.COMMA {
+		p.next()
+		s.Bg = p.expr()
+	}
+	return s
+}
+
+func (p *Parser) get() *ast.GetStmt {
+	s := &ast.GetStmt{}
+	s.Label = p.label
+	s.Get = p.accept(lex.GET)
+	s.Var = p.acceptTarget()
+	return s
+}
+
+func (p *Parser) const_() *ast.ConstStmt {
+	s := &ast.ConstStmt{}
+	s.Label = p.label
+	s.Const = p.accept(lex.CONST)
+	s.Name = p.acceptTarget()
+	p.accept(lex.EQ)
+	s.Value = p.fold(p.expr())
+	if p.consts == nil {
+		p.consts = make(map[string]ast.Expr)
+	}
+	p.consts[s.Name.Name] = s.Value
+	return s
+}
+
+func (p *Parser) local() *ast.LocalStmt {
+	s := &ast.LocalStmt{}
+	s.Label = p.label
+	s.Local = p.accept(lex.LOCAL)
+	s.Vars = append(s.Vars, p.acceptTarget())
+	for p.tok.Type == lex.COMMA {
+		p.next()
+		s.Vars = append(s.Vars, p.acceptTarget())
+	}
+	return s
+}
+
+func (p *Parser) return_() *ast.ReturnStmt {
+	s := &ast.ReturnStmt{}
+	s.Label = p.label
+	s.Return = p.accept(lex.RETURN)
+	switch p.tok.Type {
+	case lex.CR, lex.EOF, lex.ELSE:
+	default:
+		s.Value = p.expr()
 	}
-	return f1
+	return s
 }
 
 func (p *Parser) factor() ast.Expr {
@@ -381,12 +978,56 @@ func (p *Parser) factor() ast.Expr {
 	switch p.tok.Type {
 	case lex.NUMBER:
 		r = p.acceptNumber()
+	case lex.STRING:
+		r = p.acceptString()
 	case lex.LPAREN:
-		l := p.tok
-		x := p.expr()
-		r = &ast.ParenExpr{l, x, p.accept(lex.RPAREN)}
+		// A condition in parentheses is a value, so that conditions can
+		// be stored and combined with the bitwise operators.
+		l := p.accept(lex.LPAREN)
+		x := p.cond()
+		r = &ast.ParenExpr{Lparen: l, X: x, Rparen: p.accept(lex.RPAREN)}
+	case lex.PEEK:
+		// PEEK is a statement keyword, but PEEK(addr) is also a
+		// function.
+		v := ast.Variable{Pos: p.tok.Pos, Name: p.tok.Text}
+		p.next()
+		r = p.call(v)
 	default:
-		r = p.acceptVariable()
+		v := p.acceptVariable()
+		if c, ok := p.consts[v.Name]; ok {
+			switch c := c.(type) {
+			case ast.Number:
+				c.Pos = v.Pos
+				r = c
+			case ast.String:
+				c.Pos = v.Pos
+				r = c
+			}
+		} else if p.tok.Type == lex.LPAREN {
+			r = p.call(v)
+		} else {
+			r = v
+		}
 	}
 	return r
 }
+
+// call parses the arguments of a call of fn. The first argument of IIF
+// is a condition as in IF, so that it can compare values.
+func (p *Parser) call(fn ast.Variable) *ast.CallExpr {
+	c := &ast.CallExpr{Func: fn}
+	c.Lparen = p.accept(lex.LPAREN)
+	if p.tok.Type != lex.RPAREN {
+		if strings.EqualFold(fn.Name, "IIF") {
+			c.Args = append(c.Args, p.cond())
+		} else {
+			c.Args = append(c.Args, p.expr())
+		}
+		for p.tok.Type == lex.COMMA {
+			p.next()
+			c.Args = append(c.Args, p.expr())
+		}
+	}
+	c.Rparen = p.accept(lex.RPAREN)
+	return c
+}
@@ -0,0 +1,107 @@
+package parse
+
+import (
+	"github.com/qeedquan/go-ubasic/ast"
+	"github.com/qeedquan/go-ubasic/lex"
+)
+
+// Operator precedence, from loosest to tightest binding:
+//
+//	OR
+//	AND
+//	NOT
+//	=  !=  <  >  <=  >=
+//	<<  >>
+//	+  -  &  |  XOR
+//	*  /  \  %
+//	^
+//	-  +  ~  (unary)
+//
+// Binary operators group from the left, so A = B = C compares the result
+// of A = B with C, and 2 ^ 3 ^ 2 is 64, as in Microsoft BASIC. Unary
+// operators bind looser than ^, so -2 ^ 2 is -4. NOT binds looser than
+// the relational operators wherever it appears, so NOT A = B negates
+// A = B and NOT 0 + 1 negates 1.
+//
+// The logical and relational operators are only parsed in conditions,
+// in expressions in parentheses and in the operand of NOT. The binding
+// of shifts below the additive operators means 1 << N - 1 shifts by
+// N - 1.
+const (
+	precLowest = iota
+	precOr
+	precAnd
+	precNot
+	precRelation
+	precShift
+	precSum
+	precTerm
+	precPower
+	precUnary
+)
+
+// precedence returns the precedence of op as a binary operator, or
+// precLowest if it is not one.
+func precedence(op lex.Token) int {
+	switch op {
+	case lex.LOR:
+		return precOr
+	case lex.LAND:
+		return precAnd
+	case lex.EQ, lex.NEQ, lex.LT, lex.GT, lex.LEQ, lex.GEQ:
+		return precRelation
+	case lex.SHL, lex.SHR:
+		return precShift
+	case lex.PLUS, lex.MINUS, lex.AND, lex.OR, lex.XOR:
+		return precSum
+	case lex.ASTR, lex.SLASH, lex.BACKSLASH, lex.MOD:
+		return precTerm
+	case lex.POW:
+		return precPower
+	}
+	return precLowest
+}
+
+// cond parses a condition, which may use the logical and relational
+// operators.
+func (p *Parser) cond() ast.Expr {
+	return p.binary(precOr)
+}
+
+// expr parses an expression, which may not use the logical and
+// relational operators outside parentheses.
+func (p *Parser) expr() ast.Expr {
+	return p.binary(precShift)
+}
+
+// binary parses operands joined by binary operators of at least the
+// precedence min by precedence climbing.
+func (p *Parser) binary(min int) ast.Expr {
+	x := p.unary()
+	for {
+		op := p.tok
+		prec := precedence(op.Type)
+		if prec < min || prec == precLowest {
+			return x
+		}
+		p.next()
+		x = &ast.BinaryExpr{
+			Op: op,
+			X:  x,
+			Y:  p.binary(prec + 1),
+		}
+	}
+}
+
+// unary parses an operand with any unary operators before it.
+func (p *Parser) unary() ast.Expr {
+	switch op := p.tok; op.Type {
+	case lex.NOT:
+		p.next()
+		return &ast.UnaryExpr{Op: op, X: p.binary(precNot)}
+	case lex.MINUS, lex.PLUS, lex.TILDE:
+		p.next()
+		return &ast.UnaryExpr{Op: op, X: p.binary(precPower)}
+	}
+	return p.factor()
+}
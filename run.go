@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+// sandbox is the machine programs run against in batch mode. Output is
+// counted and copied to out if set, and memory is private to each program.
+// Programs are given no file system, so they cannot open files, and no
+// input, so INPUT fails at once rather than waiting on the batch.
+type sandbox struct {
+	written int
+	out     io.Writer
+	values  map[int64]int64
+}
+
+func (s *sandbox) Write(b []byte) (int, error) {
+	s.written += len(b)
+	if s.out != nil {
+		s.out.Write(b)
+	}
+	return len(b), nil
+}
+
+func (s *sandbox) Read(b []byte) (int, error) { return 0, io.EOF }
+
+func (s *sandbox) Peek(addr int64) int64  { return s.values[addr] }
+func (s *sandbox) Poke(addr, value int64) { s.values[addr] = value }
+
+// runConfig is how programs are run in batch mode.
+type runConfig struct {
+	maxSteps int64
+	timeout  time.Duration
+	options  string
+	out      io.Writer
+}
+
+type result struct {
+	name    string
+	steps   int64
+	elapsed time.Duration
+	output  int
+	err     error
+}
+
+// batch implements "ubasic run", which executes every program in the
+// given directories concurrently and prints a summary table.
+func batch(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	parallel := fs.Int("parallel", 1, "number of programs to run at once")
+	maxSteps := fs.Int64("maxsteps", 1000000, "maximum statements executed per program (0 for no limit)")
+	timeout := fs.Duration("timeout", 10*time.Second, "maximum run time per program (0 for no limit)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: run [options] dir ...")
+		fs.PrintDefaults()
+		os.Exit(exitUsage)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || *parallel < 1 {
+		fs.Usage()
+	}
+
+	names := programs(fs.Args())
+	results := make([]result, len(names))
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = runOne(name, runConfig{maxSteps: *maxSteps, timeout: *timeout})
+		}(i, name)
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PROGRAM\tSTATUS\tSTEPS\tTIME\tOUTPUT\tERROR")
+	for _, r := range results {
+		st, msg := "ok", ""
+		if r.err != nil {
+			st, msg = "error", r.err.Error()
+			if errors.Is(r.err, interp.ErrLimit) {
+				st = "limit"
+			}
+			status = exitCode(r.err)
+		}
+		msg = strings.Replace(msg, "\n", " ", -1)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%d\t%s\n", r.name, st, r.steps, r.elapsed.Round(time.Microsecond), r.output, msg)
+	}
+	w.Flush()
+}
+
+// programs returns the programs in the given directories in sorted order.
+func programs(dirs []string) []string {
+	var names []string
+	for _, dir := range dirs {
+		for _, pattern := range []string{"*.bas", "*.bas.gz"} {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if ek(err) {
+				continue
+			}
+			names = append(names, matches...)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runOne runs the named program with the limits of conf. A maxsteps
+// option in the program can only lower the limit.
+func runOne(name string, conf runConfig) (r result) {
+	r.name = name
+	start := time.Now()
+	defer func() { r.elapsed = time.Since(start) }()
+
+	src, err := interp.ReadFile(name)
+	if err != nil {
+		r.err = err
+		return
+	}
+	prog, err := interp.CompileWith(name, src, conf.options)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	mach := &sandbox{out: conf.out, values: make(map[int64]int64)}
+	p := interp.NewInterpreter(mach, interp.WithMaxSteps(conf.maxSteps), interp.WithTimeout(conf.timeout))
+	r.err = p.Run(prog)
+	r.steps = p.Steps
+	r.output = mach.written
+	return
+}
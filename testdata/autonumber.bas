@@ -0,0 +1,14 @@
+rem @option autonumber=true
+i = 0
+100 i = i + 1
+if i < 3 then 100
+print "i="; i
+if i = 3 then
+  print "three"
+else
+  print "other"
+gosub 500
+print "back"
+end
+500 print "sub"
+return
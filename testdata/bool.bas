@@ -0,0 +1,8 @@
+rem tests TRUE, FALSE and classic -1 truth values
+rem @option truevalue=-1
+
+10 a = 3
+20 ok = (a > 1) & (a < 5)
+30 print ok; " "; true; " "; false
+40 if ok = true then print "classic"
+50 if (a > 1) & 2 then print "bitwise"
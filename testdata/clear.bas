@@ -0,0 +1,16 @@
+rem tests clear
+
+10 round = 1
+20 score = 10
+30 gosub 100
+40 print "unreachable"
+100 for i = 1 to 3
+110 score = score + i
+120 if i = 2 then goto 200
+130 next i
+200 print "round"; round; " score"; score
+210 clear
+220 round = 2
+230 score = 0
+240 print "round"; round; " score"; score
+250 end
@@ -0,0 +1,8 @@
+' tests comments
+rem REM and apostrophe comments, on their own lines and after statements
+
+5 n = 0
+10 rem a numbered comment line can be jumped to
+20 n = n + 1 ' count the passes
+30 print "pass"; n rem trailing REM
+40 if n < 2 then goto 10 ' loop once more
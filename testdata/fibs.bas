@@ -6,7 +6,7 @@ rem outputs some sequence of the fibonacci
 60 for i = 0 to 20
 80 let b = a + b
 100 let a = b - a
-120 print i; ":", a, b; "\n"
+120 print i; ":", a, b
 140 next i
 
 160 end
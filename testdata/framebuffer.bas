@@ -0,0 +1,6 @@
+rem draws a color pattern; run with -framebuffer out.png
+10 for y = 0 to 47
+20 for x = 0 to 63
+30 poke 4096 + y * 64 + x, (x / 4 + y / 4) % 16
+40 next x
+50 next y
@@ -0,0 +1,8 @@
+rem tests that the statements of a function count toward the step limit,
+rem so that a function that never returns is stopped
+rem @option maxsteps=1000
+
+10 function spin(n)
+20 goto 20
+30 end function
+40 print spin(1)
@@ -0,0 +1,24 @@
+rem tests functions
+
+10 x = 7
+20 print double(x) + 1
+30 print fact(10)
+40 print greet$("bob")
+50 call double(3)
+60 print nothing()
+70 print "x="; x
+80 end
+100 function double(x)
+110 return x * 2
+120 end function
+200 function fact(n)
+210 if n <= 1 then return 1
+220 return n * fact(n - 1)
+230 end function
+300 function greet$(n$)
+310 local s$
+320 s$ = "hi " + n$
+330 return s$
+340 end function
+400 function nothing()
+410 end function
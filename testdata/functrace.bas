@@ -0,0 +1,10 @@
+rem tests that tracing follows the statements of a function called from
+rem an expression, between the statement calling it and its output
+
+10 function sq(n)
+20 m = n * n
+30 return m
+40 end function
+50 tron
+60 print sq(3)
+70 troff
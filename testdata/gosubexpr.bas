@@ -0,0 +1,14 @@
+rem tests GOSUB to a computed line number
+
+10 for level = 1 to 3
+20 gosub 100 * level
+30 next
+40 l$ = "200"
+50 gosub l$
+60 end
+100 print "one"
+110 return
+200 print "two"
+210 return
+300 print "three"
+310 return
@@ -6,4 +6,4 @@ rem tests the goto statement
 40 goto 30
 50 goto 20
 60 let c = 108
-70 print c; "\n"
+70 print c
@@ -0,0 +1,3 @@
+rem tests the integer division operator
+
+10 print 7 \ 2; " "; -7 \ 2; " "; 17 \ 3 * 3 + 17 % 3
@@ -3,11 +3,11 @@ rem tests conditional
 10 a = 30
 15 b = 20
 20 if a < b then
-30 print "a < b\n"
+30 print "a < b"
 40 else
-50 print "a > b\n"
+50 print "a > b"
 60 if a = 30 then
-70 print "a = 30\n"
+70 print "a = 30"
 80 if b != 30 then
-90 print "b != 30\n"
+90 print "b != 30"
 100 end
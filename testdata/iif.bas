@@ -0,0 +1,8 @@
+rem tests iif
+
+10 x = 0
+20 print iif(x = 0, "zero", "nonzero")
+30 print iif(x, 10 / x, -1)
+40 y = iif(x < 5, 1, 2) + 10
+50 print y
+60 print iif(x > 0 and 100 / x > 1, "big", "small")
@@ -0,0 +1,6 @@
+rem tests INCLUDE of files relative to the including file
+
+10 include "lib/area.bas"
+20 include "lib/square.bas"
+30 print rect(3, 4); " "; square(5)
+40 end
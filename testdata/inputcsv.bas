@@ -0,0 +1,16 @@
+rem tests quoted commas in INPUT # fields, which needs file access
+
+10 q$ = chr$(34)
+20 open "inputcsv.txt" for output as #1
+30 print #1, q$; "Smith, John"; q$; ",42"
+40 print #1, "Doe,  7"
+50 print #1, q$; "a, b, c"; q$; ","; q$; "d"; q$
+60 close #1
+70 open "inputcsv.txt" for input as #1
+80 input #1, n$, a
+90 print n$; "|"; a
+100 input #1, n$, a
+110 print n$; "|"; a
+120 input #1, n$, m$
+130 print n$; "|"; m$
+140 close #1
@@ -0,0 +1,10 @@
+rem tests INSTR by splitting a comma separated list
+
+10 s$ = "apple,pear,fig"
+20 i = 1
+30 j = instr(s$, ",", i)
+40 if j = 0 then j = len(s$) + 1
+50 print mid$(s$, i, j - i)
+60 i = j + 1
+70 if i <= len(s$) then goto 30
+80 print instr(s$, "pear"); " "; instr(s$, "kiwi"); " "; instr(s$, "p", 3)
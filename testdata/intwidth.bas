@@ -0,0 +1,10 @@
+rem tests that numbers wrap at the integer width wherever they come from:
+rem literals, folded constants, arithmetic and the results of builtins
+rem @option intwidth=8
+
+10 const c = 100 + 100
+20 a = 100
+30 print c
+40 print a + a
+50 print 200
+60 print val("300")
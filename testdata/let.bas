@@ -1,4 +1,4 @@
 rem tests print
 
-10 print 45+39; "\n"
+10 print 45+39
 20 end
@@ -0,0 +1,7 @@
+rem area.bas - areas of shapes, included by include.bas
+
+include "square.bas"
+
+function rect(w, h)
+return w * h
+end function
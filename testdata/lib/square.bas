@@ -0,0 +1,5 @@
+rem square.bas - included by area.bas and include.bas
+
+function square(n)
+return n * n
+end function
@@ -0,0 +1,12 @@
+rem tests local variables in recursive procedures
+
+10 a = 1
+20 call rec(3)
+30 print "a="; a
+40 end
+100 sub rec(n)
+110 local a
+120 a = n * 10
+130 if n > 0 then call rec(n - 1)
+140 print n; " "; a
+150 end sub
@@ -4,7 +4,7 @@ rem tests looping
 20   for j = 0 to 126
 30     for k = 0 to 10
 40        let a = i * j * k
-45        print i; "*"; j; "*";  k; "="; a; "\n"
+45        print i; "*"; j; "*";  k; "="; a
 50     next k
 60   next j
 70 next i
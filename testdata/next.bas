@@ -0,0 +1,12 @@
+rem tests bare next and next unwinding inner loops
+
+10 for i = 1 to 2
+20 for j = 1 to 3
+30 print i; j
+40 next
+50 next
+60 for i = 1 to 3
+70 for k = 1 to 10
+80 print "k"; i; k
+90 next i
+100 print "done"; i
@@ -0,0 +1,17 @@
+rem tests error trapping
+
+10 on error goto 100
+20 d = 0
+30 x = 10 / d
+40 print "x="; x
+50 y = 1 + "a"
+60 print "after"
+70 print f(1)
+80 end
+100 print "error"; err; " at"; erl; ": "; err$
+110 if erl != 30 then resume next
+120 d = 2
+130 resume
+200 function f(a)
+210 return a / 0
+220 end function
@@ -4,6 +4,7 @@ rem test peek and poke features
 20 poke 0, 0
 30 peek 99, a
 40 peek 0, z
-50 print a; "\n"
-60 print z; "\n"
+50 print a
+60 print z
+65 print peek(99) + 1
 70 end
@@ -0,0 +1,14 @@
+rem tests pop
+
+10 n = 0
+20 gosub 100
+30 print "never"
+40 end
+100 n = n + 1
+110 if n < 3 then gosub 100
+120 pop
+130 goto 200
+200 print "n="; n
+210 pop
+220 pop
+230 print "done"
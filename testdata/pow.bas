@@ -0,0 +1,6 @@
+rem tests ^ as exponentiation and XOR as a keyword
+rem @option exponent=true
+
+10 print 2 ^ 10; " "; -2 ^ 2; " "; 2 ^ 3 ^ 2; " "; 6 xor 3; " "; 3 * 2 ^ 2
+20 const k = 3 ^ 3
+30 print " "; k; " "; 2 ^ -1
@@ -0,0 +1,14 @@
+rem tests operator precedence and grouping
+
+5 a = 1
+6 b = 2
+7 c = 0
+20 if a = b = c then print "chain"
+30 if not a = b and not b = c or a > 5 then print "logic"
+40 print -2 * 3 + 4 << 1 - 1; " "; not 0 + 1; " "; 7 - 2 - 1; " "; 100 / 10 / 5; " "; ~1 & 6 | 1
+60 print " "; -(3); " "; 1 << 2 + 1
+70 x = not 0 + 1
+80 y = (not 0 + 1)
+90 if x != y then print "not differs"
+100 if not 0 + 1 then print "not 1"
+110 print " "; x; " "; y; " "; 1 + not 0 = 1
@@ -0,0 +1,17 @@
+rem tests procedures
+
+10 x = 5
+20 call greet("bob", 3)
+30 print "x="; x
+40 call fact(5)
+50 print "r="; r
+60 end
+100 sub greet(name$, x)
+110 print "hi "; name$; x
+120 end sub
+200 sub fact(n)
+210 if n <= 1 then r = 1 else 230
+220 return
+230 call fact(n - 1)
+240 r = r * n
+250 end sub
@@ -0,0 +1,9 @@
+rem tests RUN, keeping a count in memory since variables are cleared
+
+10 peek 0, n
+20 poke 0, n + 1
+30 print "pass"; n + 1
+40 if n = 0 then x = 5 else 60
+50 run 10
+60 if n < 2 then run
+70 print "done"
@@ -0,0 +1,24 @@
+rem tests the standard library
+
+10 include "std:strings"
+20 include "std:format"
+30 include "std:sort"
+40 print "["; lpad$("ab", 5); "]["; rpad$("ab", 5); "]["; center$("ab", 6); "]["; trim$("  x y  "); "]"
+50 print commas$(1234567); " "; commas$(-1000); " "; commas$(12); " "; zeropad$(7, 3); " "; fixed$(1234, 2); " "; fixed$(5, 2); " "; fixed$(-5, 2)
+60 dim a(5)
+70 a(0) = 5
+71 a(1) = 3
+72 a(2) = 9
+73 a(3) = 1
+74 a(4) = 3
+80 call sort(a, 5)
+90 print a(0); a(1); a(2); a(3); a(4)
+95 call reverse(a, 5)
+96 print a(0); a(1); a(2); a(3); a(4)
+100 dim b$(3)
+110 b$(0) = "pear"
+111 b$(1) = "apple"
+112 b$(2) = "fig"
+120 call sort(b$, 3)
+130 print b$(0); " "; b$(1); " "; b$(2)
+140 print trim$("   ")
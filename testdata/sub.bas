@@ -2,9 +2,9 @@ rem tests subroutines
 
 10 gosub 100
 30 for i = 1 to 10
-40 print i; "\n"
+40 print i
 50 next i
-60 print "end\n"
+60 print "end"
 70 end
-100 print "subroutine\n"
+100 print "subroutine"
 110 return
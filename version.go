@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/qeedquan/go-ubasic/interp"
+)
+
+// version may be set at link time with -ldflags "-X main.version=...".
+// Otherwise the module version recorded in the build info is used.
+var version = ""
+
+type docInfo struct {
+	Name   string `json:"name"`
+	Syntax string `json:"syntax,omitempty"`
+	Doc    string `json:"doc"`
+	Policy string `json:"policy,omitempty"`
+}
+
+type buildInfo struct {
+	Version    string           `json:"version"`
+	Go         string           `json:"go"`
+	Statements []docInfo        `json:"statements"`
+	Builtins   []docInfo        `json:"builtins"`
+	Dialects   []docInfo        `json:"dialects"`
+	Libraries  []string         `json:"libraries"`
+	Limits     map[string]int64 `json:"limits"`
+}
+
+func getVersion() string {
+	if version != "" {
+		return version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "(devel)"
+}
+
+// capabilities describes what this build of the interpreter supports.
+// It is generated from the interpreter's registries so that it cannot
+// drift from what programs can actually use.
+func capabilities() *buildInfo {
+	info := &buildInfo{
+		Version:   getVersion(),
+		Go:        runtime.Version(),
+		Libraries: interp.Libraries(),
+		Limits: map[string]int64{
+			"maxdepth": int64(interp.NewInterpreter(nil).MaxDepth),
+		},
+	}
+	for _, name := range interp.Statements() {
+		s, _ := interp.LookupStatement(name)
+		info.Statements = append(info.Statements, docInfo{Name: s.Name, Syntax: s.Syntax, Doc: s.Doc})
+	}
+	for _, name := range interp.Builtins() {
+		b, _ := interp.LookupBuiltin(name)
+		info.Builtins = append(info.Builtins, docInfo{Name: b.Name, Syntax: b.Syntax, Doc: b.Doc, Policy: b.Policy})
+	}
+	for _, name := range interp.Dialects() {
+		d, _ := interp.LookupDialect(name)
+		info.Dialects = append(info.Dialects, docInfo{Name: d.Name, Doc: d.Doc})
+	}
+	return info
+}
+
+func printVersion(asJSON bool) {
+	info := capabilities()
+	if !asJSON {
+		fmt.Printf("ubasic %s %s\n", info.Version, info.Go)
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	ek(enc.Encode(info))
+}